@@ -0,0 +1,51 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Rejects requests that do not carry the configured admin token as a bearer token
+func (app *App) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(app.Config.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.errorJson(w, errors.New("a valid admin token is required"), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Gets the number of Kroger API calls made today for each endpoint and how many are left
+func (app *App) usage(w http.ResponseWriter, r *http.Request) {
+	if app.Usage == nil {
+		app.errorJson(w, errors.New("usage is not tracked by the configured client"), http.StatusNotFound)
+		return
+	}
+
+	var usage models.UsageResponse
+	usage.Data = app.Usage.Usage()
+
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, usage)
+}
+
+// Gets how many Kroger API calls were saved by sharing identical calls made at the same time
+func (app *App) coalescing(w http.ResponseWriter, r *http.Request) {
+	if app.Coalescing == nil {
+		app.errorJson(w, errors.New("coalescing is not enabled for the configured client"), http.StatusNotFound)
+		return
+	}
+
+	var coalescing models.CoalesceResponse
+	coalescing.Data = app.Coalescing.CoalesceStats()
+
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, coalescing)
+}
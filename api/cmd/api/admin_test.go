@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// UsageReporter with a fixed report
+type staticUsage []models.EndpointUsage
+
+func (usage staticUsage) Usage() []models.EndpointUsage {
+	return usage
+}
+
+func TestUsageHandler(t *testing.T) {
+	app, _ := newTestApp()
+	app.Config.AdminToken = "letmein"
+	app.Usage = staticUsage{{Endpoint: "products", Used: 12, DailyLimit: 10000, Remaining: 9988, ResetsAt: time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC)}}
+
+	testCases := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"valid token", "Bearer letmein", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/usage", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			app.Routes().ServeHTTP(rec, req)
+
+			if rec.Code != tc.expected {
+				t.Fatalf("expected status %d but got %d", tc.expected, rec.Code)
+			} else if rec.Code != http.StatusOK {
+				return
+			}
+
+			var usage models.UsageResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &usage); err != nil {
+				t.Fatalf("failed to decode response, %v", err)
+			} else if len(usage.Data) != 1 || usage.Data[0].Remaining != 9988 {
+				t.Errorf("unexpected usage %+v", usage.Data)
+			}
+		})
+	}
+}
+
+func TestUsageHandlerDisabledWithoutToken(t *testing.T) {
+	app, _ := newTestApp()
+	app.Usage = staticUsage{}
+
+	if rec := get(app, "/admin/usage"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 but got %d", rec.Code)
+	}
+}
+
+// CoalesceReporter with fixed counts
+type staticCoalescing models.CoalesceStats
+
+func (stats staticCoalescing) CoalesceStats() models.CoalesceStats {
+	return models.CoalesceStats(stats)
+}
+
+func TestCoalescingHandler(t *testing.T) {
+	app, _ := newTestApp()
+	app.Config.AdminToken = "letmein"
+	app.Coalescing = staticCoalescing{Calls: 40, Saved: 9}
+
+	req := httptest.NewRequest("GET", "/admin/coalescing", nil)
+	req.Header.Set("Authorization", "Bearer letmein")
+	rec := httptest.NewRecorder()
+	app.Routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", rec.Code)
+	}
+	var coalescing models.CoalesceResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &coalescing); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	} else if coalescing.Data.Calls != 40 || coalescing.Data.Saved != 9 {
+		t.Errorf("unexpected stats %+v", coalescing.Data)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/domain"
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+const (
+	// Number of nearby stores compared when no filterLimit is given
+	defaultCompareStores = 5
+	// Largest number of stores that can be compared at once
+	maxCompareStores = 25
+	// Number of matching products listed per store when no productLimit is given
+	defaultCompareProducts = 5
+	// Number of stores whose products are requested from Kroger at once
+	compareConcurrency = 4
+)
+
+// Compares a product search across several stores, either the stores nearest a zip code or a list of
+// location IDs. Stores with the item in stock are listed first, cheapest first.
+func (app *App) compare(w http.ResponseWriter, r *http.Request) {
+	productQuery := kclient.ProductQuery{
+		Term:       r.URL.Query().Get("filterTerm"),
+		ProductIds: queryList(r, "productIds"),
+	}
+	locationQuery := kclient.LocationQuery{
+		ZipCode:     r.URL.Query().Get("zipcode"),
+		LocationIds: queryList(r, "locationIds"),
+	}
+
+	var err error
+	if productQuery.Term == "" && len(productQuery.ProductIds) == 0 {
+		app.errorJson(w, errors.New("one of the parameters 'filterTerm' or 'productIds' is required"), http.StatusBadRequest)
+		return
+	} else if locationQuery.ZipCode == "" && len(locationQuery.LocationIds) == 0 {
+		app.errorJson(w, errors.New("one of the parameters 'zipcode' or 'locationIds' is required"), http.StatusBadRequest)
+		return
+	}
+
+	if locationQuery.Limit, err = queryInt(r, "filterLimit"); err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	} else if locationQuery.Limit == 0 {
+		locationQuery.Limit = defaultCompareStores
+	}
+	if len(locationQuery.LocationIds) > locationQuery.Limit {
+		locationQuery.Limit = len(locationQuery.LocationIds)
+	}
+	if locationQuery.Limit < 0 || locationQuery.Limit > maxCompareStores {
+		app.errorJson(w, fmt.Errorf("up to %d stores can be compared at once", maxCompareStores), http.StatusBadRequest)
+		return
+	}
+
+	if productQuery.Limit, err = queryInt(r, "productLimit"); err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	} else if productQuery.Limit == 0 {
+		productQuery.Limit = defaultCompareProducts
+	}
+	if err := productQuery.Validate(); err != nil {
+		app.clientErrorJson(w, err)
+		return
+	}
+
+	// Get the stores to compare
+	locations, err := app.Client.GetLocations(r.Context(), locationQuery)
+	if err != nil {
+		app.clientErrorJson(w, err)
+		return
+	}
+
+	stores, err := app.compareStores(r.Context(), locations.Data, productQuery)
+	if err != nil {
+		app.clientErrorJson(w, err)
+		return
+	}
+
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, domain.CompareResponse{Data: stores})
+}
+
+// Searches for the products at each location, a few locations at a time, and sorts the stores by their best
+// in-stock price. A store whose search fails is listed with its error, unless every search fails, in which
+// case the first error is returned.
+func (app *App) compareStores(ctx context.Context, locations []models.Location, query kclient.ProductQuery) ([]domain.StoreComparison, error) {
+	stores := make([]domain.StoreComparison, len(locations))
+	errs := make([]error, len(locations))
+	now := app.currentTime()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, compareConcurrency)
+	for i, location := range locations {
+		wg.Add(1)
+		go func(i int, location models.Location) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			storeQuery := query
+			storeQuery.LocationId = location.LocationId
+			products, err := app.Client.GetProducts(ctx, storeQuery)
+			stores[i], errs[i] = compareStore(location, products, err, now), err
+		}(i, location)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 && failed == len(errs) {
+		return nil, errs[0]
+	}
+
+	// Stores with something in stock come first, cheapest first, and the rest keep their distance order
+	sort.SliceStable(stores, func(i, j int) bool {
+		if stores[i].BestPrice == nil || stores[j].BestPrice == nil {
+			return stores[i].BestPrice != nil
+		}
+		return *stores[i].BestPrice < *stores[j].BestPrice
+	})
+	return stores, nil
+}
+
+// Builds the comparison row for one store from its search results
+func compareStore(location models.Location, products *models.ProductsResponse, err error, now time.Time) domain.StoreComparison {
+	mapped := domain.StoreFromKroger(location, now)
+	store := domain.StoreComparison{
+		StoreId:  mapped.Id,
+		Chain:    mapped.Chain,
+		Name:     mapped.Name,
+		Address:  mapped.Address,
+		Products: []domain.ComparedProduct{},
+	}
+	if err != nil {
+		store.Error = err.Error()
+		return store
+	}
+
+	for _, product := range products.Data {
+		compared := domain.ProductFromKroger(product).Compared()
+		store.Products = append(store.Products, compared)
+
+		if price := compared.Price; compared.InStock && price != nil && (store.BestPrice == nil || *price < *store.BestPrice) {
+			store.BestPrice = price
+		}
+	}
+	return store
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jondysinger/grocery-data/api/pkg/domain"
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
+	"github.com/jondysinger/grocery-data/api/pkg/kclient/kclienttest"
+	"github.com/jondysinger/grocery-data/api/pkg/krogersim"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Creates an App backed by a client for the simulated Kroger API, which has different prices at each store
+func newSimApp(t *testing.T) *App {
+	sim := httptest.NewServer(krogersim.New(krogersim.DefaultFixtures(), "id", "secret"))
+	t.Cleanup(sim.Close)
+
+	client, err := kclient.New(sim.URL, "id", "secret", "FRED")
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	app, _ := newTestApp()
+	app.Client = client
+	return app
+}
+
+// Decodes a comparison response
+func decodeCompare(t *testing.T, rec *httptest.ResponseRecorder) []domain.StoreComparison {
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var compare domain.CompareResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &compare); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	}
+	return compare.Data
+}
+
+func TestCompareHandler(t *testing.T) {
+	app := newSimApp(t)
+
+	stores := decodeCompare(t, get(app, "/products/compare?productIds=0001111041700&zipcode=97224"))
+	if len(stores) < 2 {
+		t.Fatalf("expected several stores but got %d", len(stores))
+	}
+
+	// Stores with the product in stock come first, cheapest first
+	pricedStores := 0
+	for i, store := range stores {
+		if store.BestPrice == nil {
+			continue
+		}
+		pricedStores++
+		if i > 0 && (stores[i-1].BestPrice == nil || *stores[i-1].BestPrice > *store.BestPrice) {
+			t.Errorf("store %s is out of order", store.StoreId)
+		}
+	}
+	if pricedStores < 2 {
+		t.Errorf("expected prices from several stores but got %d", pricedStores)
+	}
+
+	// Stock levels and aisles are mapped the same way as on /products
+	for _, store := range stores {
+		for _, product := range store.Products {
+			switch product.StockLevel {
+			case domain.StockHigh, domain.StockLow, domain.StockOut, domain.StockUnknown:
+			default:
+				t.Errorf("unexpected stock level '%s' at store %s", product.StockLevel, store.StoreId)
+			}
+			if product.InStock != (product.StockLevel == domain.StockHigh || product.StockLevel == domain.StockLow) {
+				t.Errorf("expected in stock to match stock level '%s'", product.StockLevel)
+			}
+			if product.Aisle != "" && !strings.HasPrefix(product.Aisle, "Aisle ") {
+				t.Errorf("expected directions to the aisle but got '%s'", product.Aisle)
+			}
+		}
+	}
+}
+
+func TestCompareHandlerLocationIds(t *testing.T) {
+	app := newSimApp(t)
+
+	stores := decodeCompare(t, get(app, "/products/compare?filterTerm=milk&locationIds=70100393,70100116&productLimit=2"))
+	if len(stores) != 2 {
+		t.Fatalf("expected 2 stores but got %d", len(stores))
+	}
+	for _, store := range stores {
+		if len(store.Products) == 0 || len(store.Products) > 2 {
+			t.Errorf("expected 1 to 2 products at store %s but got %d", store.StoreId, len(store.Products))
+		}
+	}
+}
+
+func TestCompareHandlerStoreFailure(t *testing.T) {
+	app, fake := newTestApp()
+
+	var second models.Location
+	second.LocationId = "70100150"
+	fake.Locations = append(fake.Locations, second)
+	fake.FailNext(kclienttest.GetProducts, errors.New("boom"))
+
+	stores := decodeCompare(t, get(app, "/products/compare?filterTerm=milk&zipcode=97224"))
+	failed := 0
+	for _, store := range stores {
+		if store.Error != "" {
+			failed++
+		}
+	}
+	if len(stores) != 2 || failed != 1 {
+		t.Errorf("expected 2 stores with 1 failure but got %d stores with %d failures", len(stores), failed)
+	}
+
+	// When every store fails the error is returned
+	fake.Fail(kclienttest.GetProducts, &kclient.APIError{StatusCode: 500, Status: "500 Internal Server Error"})
+	if rec := get(app, "/products/compare?filterTerm=milk&zipcode=97224"); rec.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502 but got %d", rec.Code)
+	}
+}
+
+func TestCompareHandlerInvalidParam(t *testing.T) {
+	app, _ := newTestApp()
+
+	testCases := []string{
+		"/products/compare?zipcode=97224",
+		"/products/compare?filterTerm=milk",
+		"/products/compare?filterTerm=milk&zipcode=97224&filterLimit=100",
+		"/products/compare?filterTerm=milk&zipcode=97224&productLimit=51",
+		"/products/compare?filterTerm=milk&zipcode=97224&productLimit=x",
+	}
+
+	for _, target := range testCases {
+		t.Run(target, func(t *testing.T) {
+			if rec := get(app, target); rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400 but got %d", rec.Code)
+			}
+		})
+	}
+}
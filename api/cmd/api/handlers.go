@@ -1,98 +1,297 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"sort"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/jondysinger/grocery-data/api/pkg/domain"
 	"github.com/jondysinger/grocery-data/api/pkg/kclient"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
 )
 
-// Creates a Kroger API client and retrieves an OAuth2 token
-func (app *App) setupClient() (*kclient.KClient, error) {
-	var client *kclient.KClient
+const (
+	// Number of products returned by /products?all=true when no max is given
+	defaultAllProductsMax = 250
+	// Number of pages requested from Kroger at once by /products?all=true
+	allProductsConcurrency = 4
+	// Value of the sort parameter on /products that orders products by their price per unit
+	sortUnitPrice = "unitPrice"
+)
+
+// Gets locations near a zip code or coordinates, or by ID
+func (app *App) locations(w http.ResponseWriter, r *http.Request) {
+	query := kclient.LocationQuery{
+		ZipCode:       r.URL.Query().Get("zipcode"),
+		LatLong:       r.URL.Query().Get("latLong"),
+		DepartmentIds: queryList(r, "department"),
+		Chain:         r.URL.Query().Get("chain"),
+		LocationIds:   queryList(r, "locationIds"),
+	}
+
 	var err error
+	if query.Limit, err = queryInt(r, "filterLimit"); err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	}
+
+	openNow, err := queryBool(r, "openNow")
+	if err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if query.RadiusInMiles, err = queryInt(r, "radiusInMiles"); err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// Latitude and longitude are only used as a pair
+	lat, err := queryFloat(r, "lat")
+	if err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	}
+	lon, err := queryFloat(r, "lon")
+	if err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	}
+	if (lat == nil) != (lon == nil) {
+		app.errorJson(w, errors.New("parameters 'lat' and 'lon' must be given together"), http.StatusBadRequest)
+		return
+	} else if lat != nil {
+		query.Near = &kclient.Coordinates{Lat: *lat, Lon: *lon}
+	}
 
-	// Create a client for the Kroger API
-	client, err = kclient.New(
-		app.Config.KrogerApiBaseUrl,
-		app.Config.KrogerApiClientId,
-		app.Config.KrogerApiClientSecret,
-		app.Config.KrogerApiChain,
-	)
+	// Get locations matching the query
+	locations, err := app.Client.GetLocations(r.Context(), query)
 	if err != nil {
-		return nil, err
+		app.clientErrorJson(w, err)
+		return
 	}
 
-	// Authorize the client
-	if err := client.GetAuthToken(); err != nil {
-		return nil, err
+	// Leave out the closed stores and those with unknown hours if asked to
+	stores := domain.StoresFromKroger(locations, app.currentTime())
+	if openNow {
+		open := stores.Data[:0]
+		for _, store := range stores.Data {
+			if store.OpenNow != nil && *store.OpenNow {
+				open = append(open, store)
+			}
+		}
+		stores.Data = open
 	}
 
-	return client, nil
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, stores)
 }
 
-// Gets locations based on zip
-func (app *App) locations(w http.ResponseWriter, r *http.Request) {
-	zipcode := r.URL.Query().Get("zipcode")
-	filterLimit := r.URL.Query().Get("filterLimit")
+// Gets the details of a single location
+func (app *App) location(w http.ResponseWriter, r *http.Request) {
+	locationId := chi.URLParam(r, "locationId")
+
+	// Get the location by ID
+	location, err := app.Client.GetLocation(r.Context(), locationId)
+	if err != nil {
+		app.clientErrorJson(w, err)
+		return
+	}
+
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, domain.StoreResponseFromKroger(location, app.currentTime()))
+}
+
+// Gets products based on filter and location
+func (app *App) products(w http.ResponseWriter, r *http.Request) {
+	query := kclient.ProductQuery{
+		Term:        r.URL.Query().Get("filterTerm"),
+		LocationId:  r.URL.Query().Get("locationId"),
+		Brand:       r.URL.Query().Get("brand"),
+		Fulfillment: r.URL.Query().Get("fulfillment"),
+		ProductIds:  queryList(r, "productIds"),
+	}
+
+	var err error
+	if query.Start, err = queryInt(r, "filterOffset"); err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if query.Limit, err = queryInt(r, "filterLimit"); err != nil {
+		app.errorJson(w, err, http.StatusBadRequest)
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "" && sortBy != sortUnitPrice {
+		app.errorJson(w, fmt.Errorf("parameter 'sort' value '%s' is invalid. Valid values are: %s", sortBy, sortUnitPrice), http.StatusBadRequest)
+		return
+	}
 
-	filterLimitConv, err := strconv.Atoi(filterLimit)
+	all, err := queryBool(r, "all")
 	if err != nil {
 		app.errorJson(w, err, http.StatusBadRequest)
 		return
+	} else if all {
+		app.allProducts(w, r, query, sortBy)
+		return
 	}
 
-	// Setup the KClient
-	client, err := app.setupClient()
+	// Get a list of products by filter and location
+	products, err := app.Client.GetProducts(r.Context(), query)
 	if err != nil {
-		app.errorJson(w, err, http.StatusInternalServerError)
+		app.clientErrorJson(w, err)
 		return
 	}
 
-	// Get locations based on zip
-	locations, err := client.GetLocations(zipcode, filterLimitConv)
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, sortProducts(domain.ProductsFromKroger(products), sortBy))
+}
+
+// Gets up to max products in one response by walking through the pages of results, filterLimit products at a
+// time
+func (app *App) allProducts(w http.ResponseWriter, r *http.Request, query kclient.ProductQuery, sortBy string) {
+	max, err := queryInt(r, "max")
 	if err != nil {
 		app.errorJson(w, err, http.StatusBadRequest)
 		return
+	} else if max == 0 {
+		max = defaultAllProductsMax
+	} else if max < 0 || max > kclient.MaxProductStart+kclient.MaxProductLimit {
+		app.errorJson(w, fmt.Errorf("parameter 'max' value %d is invalid. Valid values are 1 to %d", max, kclient.MaxProductStart+kclient.MaxProductLimit), http.StatusBadRequest)
+		return
 	}
 
+	var products models.ProductsResponse
+	products.Data = []models.Product{}
+	first := true
+	opts := kclient.WalkOptions{PageSize: query.Limit, Max: max, Concurrency: allProductsConcurrency}
+	err = kclient.WalkProducts(r.Context(), app.Client, query, opts, func(page *models.ProductsResponse) error {
+		if first {
+			products.Meta = page.Meta
+			first = false
+		}
+		products.Data = append(products.Data, page.Data...)
+		return nil
+	})
+	if err != nil {
+		app.clientErrorJson(w, err)
+		return
+	}
+	products.Meta.Pagination.Start = query.Start
+	products.Meta.Pagination.Limit = len(products.Data)
+
 	// Write the json response
-	_ = app.writeJson(w, http.StatusOK, locations)
+	_ = app.writeJson(w, http.StatusOK, sortProducts(domain.ProductsFromKroger(&products), sortBy))
 }
 
-// Gets products based on filter and location
-func (app *App) products(w http.ResponseWriter, r *http.Request) {
-	filterTerm := r.URL.Query().Get("filterTerm")
+// Sorts products by the unit price of their first offer when sortBy is unitPrice. Prices per ounce, fluid
+// ounce and item cannot be compared, so products priced in the same unit as the first result come first and
+// the rest follow grouped by unit. Products without a unit price go last.
+func sortProducts(products domain.ProductsResponse, sortBy string) domain.ProductsResponse {
+	if sortBy != sortUnitPrice {
+		return products
+	}
+
+	var firstUnit string
+	for _, product := range products.Data {
+		if unitPrice := product.UnitPrice(); unitPrice != nil {
+			firstUnit = unitPrice.Unit
+			break
+		}
+	}
+
+	// Ranks a unit price by how comparable it is with the first result's
+	rank := func(unitPrice *domain.UnitPrice) int {
+		if unitPrice == nil {
+			return 2
+		} else if unitPrice.Unit != firstUnit {
+			return 1
+		}
+		return 0
+	}
+
+	sort.SliceStable(products.Data, func(i, j int) bool {
+		a, b := products.Data[i].UnitPrice(), products.Data[j].UnitPrice()
+		if rankA, rankB := rank(a), rank(b); rankA != rankB {
+			return rankA < rankB
+		} else if a == nil {
+			return false
+		} else if a.Unit != b.Unit {
+			return a.Unit < b.Unit
+		}
+		return a.Price < b.Price
+	})
+	return products
+}
+
+// Gets the details of a single product, optionally for a given location
+func (app *App) product(w http.ResponseWriter, r *http.Request) {
+	productId := chi.URLParam(r, "productId")
 	locationId := r.URL.Query().Get("locationId")
-	filterOffset := r.URL.Query().Get("filterOffset")
-	filterLimit := r.URL.Query().Get("filterLimit")
 
-	filterOffsetConv, err := strconv.Atoi(filterOffset)
+	// Get the product by ID and location
+	product, err := app.Client.GetProduct(r.Context(), productId, locationId)
 	if err != nil {
-		app.errorJson(w, err, http.StatusBadRequest)
+		app.clientErrorJson(w, err)
 		return
 	}
 
-	filterLimitConv, err := strconv.Atoi(filterLimit)
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, domain.ProductResponseFromKroger(product))
+}
+
+// Gets all chains
+func (app *App) chains(w http.ResponseWriter, r *http.Request) {
+	chains, err := app.Client.GetChains(r.Context())
 	if err != nil {
-		app.errorJson(w, err, http.StatusBadRequest)
+		app.clientErrorJson(w, err)
 		return
 	}
 
-	// Setup the KClient
-	client, err := app.setupClient()
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, domain.ChainsFromKroger(chains))
+}
+
+// Gets the details of a single chain
+func (app *App) chain(w http.ResponseWriter, r *http.Request) {
+	chainName := chi.URLParam(r, "chainName")
+
+	chain, err := app.Client.GetChain(r.Context(), chainName)
 	if err != nil {
-		app.errorJson(w, err, http.StatusInternalServerError)
+		app.clientErrorJson(w, err)
 		return
 	}
 
-	// Get a list of products by filter and location
-	products, err := client.GetProducts(filterTerm, locationId, filterOffsetConv, filterLimitConv)
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, domain.ChainResponseFromKroger(chain))
+}
+
+// Gets all departments
+func (app *App) departments(w http.ResponseWriter, r *http.Request) {
+	departments, err := app.Client.GetDepartments(r.Context())
 	if err != nil {
-		app.errorJson(w, err, http.StatusBadRequest)
+		app.clientErrorJson(w, err)
+		return
+	}
+
+	// Write the json response
+	_ = app.writeJson(w, http.StatusOK, domain.DepartmentsFromKroger(departments))
+}
+
+// Gets the details of a single department
+func (app *App) department(w http.ResponseWriter, r *http.Request) {
+	departmentId := chi.URLParam(r, "departmentId")
+
+	department, err := app.Client.GetDepartment(r.Context(), departmentId)
+	if err != nil {
+		app.clientErrorJson(w, err)
 		return
 	}
 
 	// Write the json response
-	_ = app.writeJson(w, http.StatusOK, products)
+	_ = app.writeJson(w, http.StatusOK, domain.DepartmentResponseFromKroger(department))
 }
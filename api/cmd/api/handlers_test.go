@@ -0,0 +1,520 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/cache"
+	"github.com/jondysinger/grocery-data/api/pkg/domain"
+	"github.com/jondysinger/grocery-data/api/pkg/envcfg"
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
+	"github.com/jondysinger/grocery-data/api/pkg/kclient/kclienttest"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+	"github.com/jondysinger/grocery-data/api/pkg/money"
+	"github.com/jondysinger/grocery-data/api/pkg/unitsize"
+)
+
+// Creates an App backed by a fake client with a few stores and products
+func newTestApp() (*App, *kclienttest.Fake) {
+	fake := kclienttest.New()
+
+	var store models.Location
+	store.LocationId = "70100393"
+	store.Chain = "FRED"
+	store.Name = "Fred Meyer - Tigard"
+	store.Departments = []models.Department{{DepartmentID: "09", Name: "Pharmacy"}}
+	fake.Locations = []models.Location{store}
+
+	fake.Products = []models.Product{
+		{ProductId: "0001111041700", Brand: "Kroger", Description: "Kroger 2% Reduced Fat Milk"},
+		{ProductId: "0004138703023", Brand: "Darigold", Description: "Darigold Whole Milk"},
+	}
+	// Only the Kroger milk can be picked up at the curb
+	for i := range fake.Products {
+		var item models.Item
+		item.Fulfillment.InStore = true
+		item.Fulfillment.Curbside = i == 0
+		fake.Products[i].Items = []models.Item{item}
+	}
+	fake.Chains = []models.Chain{{Name: "FRED", DivisionNumbers: []string{"701"}}}
+	fake.Departments = []models.Department{{DepartmentID: "09", Name: "Pharmacy"}}
+
+	app := &App{
+		Config: &envcfg.EnvCfg{GroceryDataAppUrl: "http://localhost:3000"},
+		Client: fake,
+	}
+	return app, fake
+}
+
+// Sends a GET request through the app's routes
+func get(app *App, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.Routes().ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+	return rec
+}
+
+// Decodes an error response body
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) models.JsonResponse {
+	var payload models.JsonResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to decode error response, %v", err)
+	}
+	return payload
+}
+
+func TestLocationsHandler(t *testing.T) {
+	app, fake := newTestApp()
+
+	rec := get(app, "/locations?zipcode=97224&filterLimit=10&department=09&radiusInMiles=5")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var locations domain.StoresResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &locations); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	} else if len(locations.Data) != 1 {
+		t.Fatalf("expected 1 location but got %d", len(locations.Data))
+	} else if store := locations.Data[0]; store.Id != "70100393" || store.Name != "Fred Meyer - Tigard" || store.Departments[0].Id != "09" {
+		t.Errorf("unexpected store %+v", store)
+	}
+
+	calls := fake.Calls(kclienttest.GetLocations)
+	query := calls[0].Args[0].(kclient.LocationQuery)
+	if query.ZipCode != "97224" || query.Limit != 10 || query.RadiusInMiles != 5 || len(query.DepartmentIds) != 1 {
+		t.Errorf("unexpected query %+v", query)
+	}
+}
+
+func TestLocationsHandlerCoordinates(t *testing.T) {
+	app, fake := newTestApp()
+
+	if rec := get(app, "/locations?lat=45.43&lon=-122.76"); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	query := fake.Calls(kclienttest.GetLocations)[0].Args[0].(kclient.LocationQuery)
+	if query.Near == nil || query.Near.Lat != 45.43 || query.Near.Lon != -122.76 {
+		t.Errorf("unexpected coordinates %+v", query.Near)
+	}
+}
+
+func TestLocationsHandlerOpenNow(t *testing.T) {
+	app, fake := newTestApp()
+	// Wednesday 1 June 2022 at 22:30 in Portland
+	app.now = func() time.Time { return time.Date(2022, 6, 2, 5, 30, 0, 0, time.UTC) }
+
+	daily := func(opens string, closes string) models.StoreHours {
+		day := models.DayHours{Open: opens, Close: closes}
+		return models.StoreHours{Timezone: "America/Los_Angeles", Monday: day, Tuesday: day, Wednesday: day, Thursday: day, Friday: day, Saturday: day, Sunday: day}
+	}
+	stores := []struct {
+		id    string
+		hours models.StoreHours
+	}{
+		{"70100001", daily("06:00", "23:00")},
+		{"70100002", daily("06:00", "22:00")},
+		{"70100003", models.StoreHours{Open24: true}},
+		{"70100004", models.StoreHours{}},
+	}
+	fake.Locations = nil
+	for _, store := range stores {
+		var location models.Location
+		location.LocationId = store.id
+		location.Chain = "FRED"
+		location.Hours = store.hours
+		fake.Locations = append(fake.Locations, location)
+	}
+
+	testCases := []struct {
+		name     string
+		target   string
+		expected []string
+	}{
+		{"all", "/locations?zipcode=97224", []string{"70100001", "70100002", "70100003", "70100004"}},
+		{"open now", "/locations?zipcode=97224&openNow=true", []string{"70100001", "70100003"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := get(app, tc.target)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var locations domain.StoresResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &locations); err != nil {
+				t.Fatalf("failed to decode response, %v", err)
+			}
+			var ids []string
+			for _, store := range locations.Data {
+				ids = append(ids, store.Id)
+			}
+			if !reflect.DeepEqual(ids, tc.expected) {
+				t.Errorf("expected locations %v but got %v", tc.expected, ids)
+			}
+		})
+	}
+
+	// Open and closing times are only given when they are known
+	var locations domain.StoresResponse
+	if err := json.Unmarshal(get(app, "/locations?zipcode=97224").Body.Bytes(), &locations); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	}
+	expectedCloses := time.Date(2022, 6, 2, 6, 0, 0, 0, time.UTC)
+	if open := locations.Data[0].OpenNow; open == nil || !*open {
+		t.Errorf("expected the first store to be open")
+	} else if closes := locations.Data[0].ClosesAt; closes == nil || !closes.Equal(expectedCloses) {
+		t.Errorf("expected the first store to close at %v but got %v", expectedCloses, closes)
+	}
+	if open := locations.Data[1].OpenNow; open == nil || *open || locations.Data[1].ClosesAt != nil {
+		t.Errorf("expected the second store to be closed")
+	}
+	if locations.Data[2].ClosesAt != nil {
+		t.Errorf("expected the 24 hour store to have no closing time")
+	}
+	if locations.Data[3].OpenNow != nil {
+		t.Errorf("expected the store with unknown hours to have no open status")
+	}
+}
+
+func TestLocationsHandlerInvalidParam(t *testing.T) {
+	app, _ := newTestApp()
+	testCases := []struct {
+		name   string
+		target string
+	}{
+		{"limit not a number", "/locations?zipcode=97224&filterLimit=ten"},
+		{"openNow not a boolean", "/locations?zipcode=97224&openNow=maybe"},
+		{"lat without lon", "/locations?lat=45.43"},
+		{"lat and lon not numbers", "/locations?lat=NaN&lon=NaN"},
+		{"lon infinite", "/locations?lat=45.43&lon=-Inf"},
+		{"latLong not numbers", "/locations?latLong=NaN,NaN"},
+		{"zip code invalid", "/locations?zipcode=1234"},
+		{"no origin", "/locations"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := get(app, tc.target)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400 but got %d", rec.Code)
+			}
+			if payload := decodeError(t, rec); !payload.Error || payload.Code != errCodeInvalidRequest {
+				t.Errorf("unexpected error payload %+v", payload)
+			}
+		})
+	}
+}
+
+func TestProductsHandler(t *testing.T) {
+	app, fake := newTestApp()
+
+	rec := get(app, "/products?filterTerm=milk&locationId=70100393&filterOffset=0&filterLimit=25&brand=Kroger&fulfillment=csp")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var products domain.ProductsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	} else if len(products.Data) != 1 || products.Data[0].Id != "0001111041700" {
+		t.Fatalf("expected the Kroger milk but got %+v", products.Data)
+	}
+
+	query := fake.Calls(kclienttest.GetProducts)[0].Args[0].(kclient.ProductQuery)
+	if query.Fulfillment != kclient.FulfillmentCurbside || query.Limit != 25 {
+		t.Errorf("unexpected query %+v", query)
+	}
+
+	// Products that cannot be obtained with the fulfillment type are left out
+	rec = get(app, "/products?filterTerm=milk&locationId=70100393&fulfillment=csp")
+	products = domain.ProductsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	} else if len(products.Data) != 1 || products.Data[0].Id != "0001111041700" {
+		t.Errorf("expected only the Kroger milk but got %+v", products.Data)
+	}
+}
+
+func TestProductHandler(t *testing.T) {
+	app, fake := newTestApp()
+
+	rec := get(app, "/products/0004138703023?locationId=70100393")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	call := fake.Calls(kclienttest.GetProduct)[0]
+	if call.Args[0] != "0004138703023" || call.Args[1] != "70100393" {
+		t.Errorf("unexpected call %+v", call)
+	}
+
+	if rec := get(app, "/products/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 but got %d", rec.Code)
+	}
+}
+
+func TestCatalogHandlers(t *testing.T) {
+	app, _ := newTestApp()
+	testCases := []struct {
+		target   string
+		expected int
+	}{
+		{"/locations/70100393", http.StatusOK},
+		{"/locations/99999999", http.StatusNotFound},
+		{"/chains", http.StatusOK},
+		{"/chains/FRED", http.StatusOK},
+		{"/departments", http.StatusOK},
+		{"/departments/09", http.StatusOK},
+		{"/departments/99", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			if rec := get(app, tc.target); rec.Code != tc.expected {
+				t.Errorf("expected status %d but got %d", tc.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestProductsHandlerAll(t *testing.T) {
+	app, fake := newTestApp()
+
+	rec := get(app, "/products?filterTerm=milk&filterLimit=1&all=true&max=2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var products domain.ProductsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	} else if len(products.Data) != 2 || products.Meta.Pagination.Limit != 2 {
+		t.Fatalf("expected 2 products but got %d", len(products.Data))
+	}
+	if calls := fake.Calls(kclienttest.GetProducts); len(calls) != 2 {
+		t.Errorf("expected a request per page but got %d", len(calls))
+	}
+
+	for _, target := range []string{"/products?filterTerm=milk&all=maybe", "/products?filterTerm=milk&all=true&max=5000"} {
+		if rec := get(app, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s but got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestProductsHandlerSortUnitPrice(t *testing.T) {
+	app := newSimApp(t)
+
+	rec := get(app, "/products?filterTerm=milk&locationId=70100393&sort=unitPrice")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var products domain.ProductsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	} else if len(products.Data) < 2 {
+		t.Fatalf("expected several products but got %d", len(products.Data))
+	}
+
+	// A half gallon costs more per fluid ounce than a gallon, so prices are compared per unit rather than per package
+	var previous money.Money
+	for _, product := range products.Data {
+		unitPrice := product.UnitPrice()
+		if unitPrice == nil {
+			t.Fatalf("expected a unit price for %s", product.Id)
+		} else if unitPrice.Unit != unitsize.FluidOunce.Name {
+			t.Errorf("expected a price per fl oz but got per %s", unitPrice.Unit)
+		} else if unitPrice.Price < previous {
+			t.Errorf("expected products sorted by unit price but %s came after %s", unitPrice.Price, previous)
+		}
+		previous = unitPrice.Price
+	}
+
+	if rec := get(app, "/products?filterTerm=milk&sort=cheapest"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 but got %d", rec.Code)
+	}
+}
+
+func TestProductsHandlerSortUnitPriceMixedUnits(t *testing.T) {
+	app, fake := newTestApp()
+
+	// Builds a product with a single item at the given price and size
+	product := func(id string, price string, size string) models.Product {
+		item := models.Item{ItemId: id, Size: size, SoldBy: "UNIT"}
+		item.Price.Regular = money.MustParse(price)
+		return models.Product{ProductId: id, Brand: "Kroger", Description: "Kroger Eggs", Items: []models.Item{item}}
+	}
+	fake.Products = []models.Product{
+		product("0000000000001", "4.80", "16 oz"),
+		product("0000000000002", "1.20", "12 ct"),
+		product("0000000000003", "2.40", "16 oz"),
+		{ProductId: "0000000000004", Brand: "Kroger", Description: "Kroger Eggs"},
+		product("0000000000005", "6.00", "12 ct"),
+		product("0000000000006", "3.20", "16 oz"),
+	}
+
+	rec := get(app, "/products?filterTerm=eggs&sort=unitPrice")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var products domain.ProductsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	}
+
+	// Ounces come first as the first result is priced per ounce, even though a dozen costs less per item
+	var ids []string
+	for _, product := range products.Data {
+		ids = append(ids, product.Id)
+	}
+	expected := []string{"0000000000003", "0000000000006", "0000000000001", "0000000000002", "0000000000005", "0000000000004"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected products in order %v but got %v", expected, ids)
+	}
+}
+
+func TestProductsHandlerShelfLocations(t *testing.T) {
+	app := newSimApp(t)
+
+	rec := get(app, "/products?filterTerm=milk&locationId=70100393&brand=Kroger")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var products domain.ProductsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	} else if len(products.Data) == 0 {
+		t.Fatalf("expected products but got none")
+	}
+	for _, product := range products.Data {
+		if len(product.ShelfLocations) == 0 {
+			t.Fatalf("expected shelf locations for %s", product.Id)
+		}
+		for _, location := range product.ShelfLocations {
+			if location.Aisle == 0 || location.Directions == "" || location.SortKey == "" {
+				t.Errorf("expected a parsed shelf location but got %+v", location)
+			}
+		}
+	}
+
+	// Without a location there are no aisles to parse
+	var unlocated domain.ProductsResponse
+	rec = get(app, "/products?filterTerm=milk")
+	if err := json.Unmarshal(rec.Body.Bytes(), &unlocated); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	}
+	for _, product := range unlocated.Data {
+		if len(product.ShelfLocations) != 0 {
+			t.Errorf("expected no shelf locations without a location but got %+v", product.ShelfLocations)
+		}
+	}
+}
+
+func TestCacheHeader(t *testing.T) {
+	app, fake := newTestApp()
+
+	// The fake does not cache so no header is written
+	if rec := get(app, "/chains"); rec.Header().Get("X-Cache") != "" {
+		t.Errorf("expected no X-Cache header but got %s", rec.Header().Get("X-Cache"))
+	}
+
+	app.Client = cache.New(fake, cache.NewLRU(1<<20), cache.DefaultOptions())
+	for _, expected := range []string{cache.Miss, cache.Hit} {
+		rec := get(app, "/products?filterTerm=milk")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200 but got %d", rec.Code)
+		} else if header := rec.Header().Get("X-Cache"); header != expected {
+			t.Errorf("expected X-Cache %s but got %s", expected, header)
+		}
+	}
+}
+
+func TestStaleResponseHeaders(t *testing.T) {
+	app, fake := newTestApp()
+	opts := cache.DefaultOptions()
+	opts.ProductTtl = time.Nanosecond
+	opts.StaleWhileRevalidate = 0
+	app.Client = cache.New(fake, cache.NewLRU(1<<20), opts)
+
+	if rec := get(app, "/products?filterTerm=milk"); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", rec.Code)
+	}
+	time.Sleep(time.Millisecond)
+
+	// Kroger failing after the response expired gets the stale response
+	fake.FailNext(kclienttest.GetProducts, &kclient.APIError{StatusCode: 503, Status: "503 Service Unavailable"})
+	rec := get(app, "/products?filterTerm=milk")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", rec.Code)
+	}
+	if header := rec.Header().Get("X-Cache"); header != cache.Stale {
+		t.Errorf("expected X-Cache %s but got %s", cache.Stale, header)
+	}
+	if header := rec.Header().Get("Warning"); header != cache.WarningRevalidationFailed {
+		t.Errorf("expected Warning %s but got %s", cache.WarningRevalidationFailed, header)
+	}
+	if rec.Header().Get("Age") == "" {
+		t.Error("expected an Age header")
+	}
+
+	var products domain.ProductsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &products); err != nil {
+		t.Fatalf("failed to decode response, %v", err)
+	} else if !products.Meta.Stale {
+		t.Error("expected the response to be marked stale")
+	}
+}
+
+func TestClientErrorStatus(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		statusCode int
+		code       string
+	}{
+		{"validation", &kclient.ValidationError{Message: "bad"}, http.StatusBadRequest, errCodeInvalidRequest},
+		{"kroger bad request", &kclient.APIError{StatusCode: 400}, http.StatusBadRequest, errCodeInvalidRequest},
+		{"not found", &kclient.APIError{StatusCode: 404}, http.StatusNotFound, errCodeNotFound},
+		{"bad credentials", &kclient.APIError{StatusCode: 401}, http.StatusBadGateway, errCodeUpstreamAuth},
+		{"rate limited", &kclient.APIError{StatusCode: 429}, http.StatusServiceUnavailable, errCodeUpstreamRateLimited},
+		{"outage", &kclient.APIError{StatusCode: 503}, http.StatusBadGateway, errCodeUpstreamError},
+		{"response too large", fmt.Errorf("%w: body is longer than 16 bytes", kclient.ErrResponseTooLarge), http.StatusBadGateway, errCodeUpstreamError},
+		{"quota exceeded", &kclient.LimitError{Endpoint: "products", Limit: 10, Daily: true}, http.StatusServiceUnavailable, errCodeQuotaExceeded},
+		{"timeout", context.DeadlineExceeded, http.StatusGatewayTimeout, errCodeUpstreamTimeout},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError, errCodeInternal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			statusCode, code := clientErrorStatus(tc.err)
+			if statusCode != tc.statusCode || code != tc.code {
+				t.Errorf("expected (%d, %s) but got (%d, %s)", tc.statusCode, tc.code, statusCode, code)
+			}
+		})
+	}
+}
+
+func TestHandlerReturnsInjectedError(t *testing.T) {
+	app, fake := newTestApp()
+	fake.Fail(kclienttest.GetProducts, &kclient.APIError{StatusCode: 500, Status: "500 Internal Server Error"})
+
+	rec := get(app, "/products?filterTerm=milk")
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected status 502 but got %d", rec.Code)
+	}
+	if payload := decodeError(t, rec); payload.Code != errCodeUpstreamError {
+		t.Errorf("expected code %s but got %s", errCodeUpstreamError, payload.Code)
+	}
+}
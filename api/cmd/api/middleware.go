@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/cache"
+)
+
+// Response writer that adds the X-Cache, Age and Warning headers just before the response is written, once
+// the handler has made its calls to the client
+type cacheStatusWriter struct {
+	http.ResponseWriter
+	status      *cache.Status
+	wroteHeader bool
+}
+
+func (w *cacheStatusWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if value := w.status.Header(); value != "" {
+			w.Header().Set("X-Cache", value)
+			if value != cache.Miss {
+				w.Header().Set("Age", strconv.Itoa(int(w.status.Age()/time.Second)))
+			}
+			for _, warning := range w.status.Warnings() {
+				w.Header().Add("Warning", warning)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *cacheStatusWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Reports whether the client answered a request from its cache with an X-Cache header of HIT, MISS or STALE.
+// Cached responses also get an Age header, and stale ones a Warning header. No headers are written when the
+// client does not cache.
+func cacheStatus(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, status := cache.WithStatus(r.Context())
+		next.ServeHTTP(&cacheStatusWriter{ResponseWriter: w, status: status}, r.WithContext(ctx))
+	})
+}
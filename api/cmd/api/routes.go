@@ -2,15 +2,32 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/jondysinger/grocery-data/api/pkg/envcfg"
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
 )
 
 type App struct {
 	Config *envcfg.EnvCfg
+	Client kclient.GroceryClient
+	// Optional source of Kroger API usage for the admin routes
+	Usage kclient.UsageReporter
+	// Optional source of request coalescing counts for the admin routes
+	Coalescing kclient.CoalesceReporter
+	// Source of the current time for store hours, time.Now when nil
+	now func() time.Time
+}
+
+// Gets the current time
+func (app *App) currentTime() time.Time {
+	if app.now == nil {
+		return time.Now()
+	}
+	return app.now()
 }
 
 func (app *App) Routes() http.Handler {
@@ -20,15 +37,32 @@ func (app *App) Routes() http.Handler {
 		AllowedOrigins:   []string{app.Config.GroceryDataAppUrl},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "X-Cache", "Age", "Warning"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 
 	r.Use(middleware.Recoverer)
+	r.Use(cacheStatus)
 
 	r.Get("/locations", app.locations)
+	r.Get("/locations/{locationId}", app.location)
 	r.Get("/products", app.products)
+	r.Get("/products/compare", app.compare)
+	r.Get("/products/{productId}", app.product)
+	r.Get("/chains", app.chains)
+	r.Get("/chains/{chainName}", app.chain)
+	r.Get("/departments", app.departments)
+	r.Get("/departments/{departmentId}", app.department)
+
+	// Admin routes are only served when a token to protect them is configured
+	if app.Config.AdminToken != "" {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.requireAdmin)
+			r.Get("/usage", app.usage)
+			r.Get("/coalescing", app.coalescing)
+		})
+	}
 
 	return r
 }
@@ -1,12 +1,35 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
 	"github.com/jondysinger/grocery-data/api/pkg/models"
 )
 
+// Machine-readable error codes returned in the code field of error responses
+const (
+	errCodeInvalidRequest      = "invalid_request"
+	errCodeNotFound            = "not_found"
+	errCodeUpstreamAuth        = "upstream_unauthorized"
+	errCodeUpstreamRateLimited = "upstream_rate_limited"
+	errCodeUpstreamError       = "upstream_error"
+	errCodeUpstreamUnavailable = "upstream_unavailable"
+	errCodeUpstreamTimeout     = "upstream_timeout"
+	errCodeQuotaExceeded       = "quota_exceeded"
+	errCodeUnauthorized        = "unauthorized"
+	errCodeInternal            = "internal_error"
+)
+
 // Writes the given data as a json response
 func (app *App) writeJson(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
 	out, err := json.Marshal(data)
@@ -32,9 +55,123 @@ func (app *App) writeJson(w http.ResponseWriter, status int, data interface{}, h
 
 // Writes an error as a json response with the given status code
 func (app *App) errorJson(w http.ResponseWriter, err error, statusCode int) error {
+	code := errCodeInternal
+	switch statusCode {
+	case http.StatusBadRequest:
+		code = errCodeInvalidRequest
+	case http.StatusNotFound:
+		code = errCodeNotFound
+	case http.StatusUnauthorized:
+		code = errCodeUnauthorized
+	}
+
+	return app.writeError(w, err, statusCode, code)
+}
+
+// Writes an error returned by the Kroger API client as a json response, choosing the status code based on
+// what went wrong. Failures on Kroger's side are reported as gateway errors rather than client errors.
+func (app *App) clientErrorJson(w http.ResponseWriter, err error) error {
+	statusCode, code := clientErrorStatus(err)
+
+	// Tell the caller when the client's own limits allow the request again
+	var limitErr *kclient.LimitError
+	if errors.As(err, &limitErr) {
+		seconds := int(math.Ceil(time.Until(limitErr.RetryAt).Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	return app.writeError(w, err, statusCode, code)
+}
+
+// Maps an error returned by the Kroger API client to a status code and error code
+func clientErrorStatus(err error) (int, string) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, kclient.ErrValidation):
+		return http.StatusBadRequest, errCodeInvalidRequest
+	case errors.Is(err, kclient.ErrNotFound):
+		return http.StatusNotFound, errCodeNotFound
+	case errors.Is(err, kclient.ErrUnauthorized):
+		return http.StatusBadGateway, errCodeUpstreamAuth
+	case errors.Is(err, kclient.ErrRateLimited):
+		return http.StatusServiceUnavailable, errCodeUpstreamRateLimited
+	case errors.Is(err, kclient.ErrQuotaExceeded):
+		return http.StatusServiceUnavailable, errCodeQuotaExceeded
+	case errors.Is(err, kclient.ErrUpstream), errors.Is(err, kclient.ErrResponseTooLarge):
+		return http.StatusBadGateway, errCodeUpstreamError
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusGatewayTimeout, errCodeUpstreamTimeout
+	case errors.As(err, &netErr):
+		return http.StatusServiceUnavailable, errCodeUpstreamUnavailable
+	}
+	return http.StatusInternalServerError, errCodeInternal
+}
+
+// Writes an error json response with the given status code and error code
+func (app *App) writeError(w http.ResponseWriter, err error, statusCode int, code string) error {
 	var payload models.JsonResponse
 	payload.Error = true
+	payload.Code = code
 	payload.Message = err.Error()
 
 	return app.writeJson(w, statusCode, payload)
 }
+
+// Gets an optional integer query parameter, which is zero when not given
+func queryInt(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("parameter '%s' value '%s' is invalid. Must be a whole number", name, value)
+	}
+	return number, nil
+}
+
+// Gets an optional boolean query parameter, which is false when not given
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("parameter '%s' value '%s' is invalid. Must be true or false", name, value)
+	}
+	return flag, nil
+}
+
+// Gets an optional decimal query parameter, which is nil when not given
+func queryFloat(r *http.Request, name string) (*float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return nil, fmt.Errorf("parameter '%s' value '%s' is invalid. Must be a number", name, value)
+	}
+	return &number, nil
+}
+
+// Gets an optional comma separated list query parameter
+func queryList(r *http.Request, name string) []string {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil
+	}
+
+	items := strings.Split(value, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
@@ -0,0 +1,33 @@
+// Command krogersim runs the simulated Kroger API for local development. Point KROGER_API_BASE_URL at it
+// and use the same client ID and secret in the API's environment.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/jondysinger/grocery-data/api/pkg/krogersim"
+)
+
+func main() {
+	addr := flag.String("addr", ":5050", "address to listen on")
+	fixtureDir := flag.String("fixtures", "", "directory of fixture files to use instead of the built-in ones")
+	clientId := flag.String("client-id", "krogersim", "client ID accepted by the token endpoint")
+	clientSecret := flag.String("client-secret", "krogersim", "client secret accepted by the token endpoint")
+	flag.Parse()
+
+	fixtures := krogersim.DefaultFixtures()
+	if *fixtureDir != "" {
+		var err error
+		if fixtures, err = krogersim.LoadFixtureDir(*fixtureDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Printf("simulated Kroger API listening on %s", *addr)
+	err := http.ListenAndServe(*addr, krogersim.New(fixtures, *clientId, *clientSecret))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/jondysinger/grocery-data/api/cmd/api"
+	"github.com/jondysinger/grocery-data/api/pkg/cache"
 	"github.com/jondysinger/grocery-data/api/pkg/envcfg"
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
 )
 
 func main() {
@@ -15,9 +23,104 @@ func main() {
 	// Get environment variables
 	app.Config = envcfg.Get()
 
-	// Start a web server
-	err := http.ListenAndServe(fmt.Sprintf(":%s", app.Config.Port), app.Routes())
+	// Create a single Kroger API client shared by all requests so its OAuth2 token is reused
+	client, err := kclient.New(
+		app.Config.KrogerApiBaseUrl,
+		app.Config.KrogerApiClientId,
+		app.Config.KrogerApiClientSecret,
+		app.Config.KrogerApiChain,
+		kclient.WithRateLimits(rateLimits(app.Config)),
+		kclient.WithQuotaStore(quotaStore(app.Config)),
+	)
 	if err != nil {
 		log.Fatal(err)
 	}
+	app.Usage = client
+
+	// Share one Kroger API call between identical requests made at the same time, such as a burst of users
+	// searching for the same product
+	coalesced := kclient.NewCoalescingClient(client)
+	app.Coalescing = coalesced
+
+	// Cache responses so repeated searches do not use up the Kroger API quota
+	store, err := cacheStore(app.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cached := cache.New(coalesced, store, cache.Options{
+		LocationTtl:          app.Config.CacheLocationTtl,
+		ProductTtl:           app.Config.CacheProductTtl,
+		StaleIfError:         app.Config.CacheStaleIfError,
+		StaleWhileRevalidate: app.Config.CacheStaleWhileRevalidate,
+	})
+	app.Client = cached
+
+	// Start a web server, stopping it gracefully on an interrupt so the Kroger API usage is saved
+	server := &http.Server{Addr: fmt.Sprintf(":%s", app.Config.Port), Handler: app.Routes()}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("failed to shut down the web server: %v", err)
+		}
+	}()
+
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+
+	// Wait for requests in progress and background refreshes of stale responses to finish before saving, so
+	// the Kroger API calls they make are counted
+	<-stopped
+	cached.Wait()
+	client.Close()
+	if closer, ok := store.(interface{ Close() error }); ok {
+		closer.Close()
+	}
+}
+
+// Builds the Kroger API rate limits from the configuration, applying the same throttle to every endpoint
+func rateLimits(cfg *envcfg.EnvCfg) map[string]kclient.RateLimit {
+	limits := make(map[string]kclient.RateLimit)
+	for _, endpoint := range []string{kclient.EndpointLocations, kclient.EndpointProducts, kclient.EndpointChains, kclient.EndpointDepartments} {
+		limits[endpoint] = kclient.RateLimit{
+			PerSecond: cfg.KrogerApiRateLimit,
+			Burst:     cfg.KrogerApiRateBurst,
+			MaxWait:   cfg.KrogerApiMaxWait,
+			Daily:     cfg.KrogerApiDailyLimits[endpoint],
+		}
+	}
+	return limits
+}
+
+// Gets the store the daily Kroger API call counts are saved to, or nil to keep them in memory
+func quotaStore(cfg *envcfg.EnvCfg) kclient.QuotaStore {
+	if cfg.KrogerApiUsageFile == "" {
+		return nil
+	}
+	return kclient.NewFileQuotaStore(cfg.KrogerApiUsageFile)
+}
+
+// Creates the store for cached responses selected by the configuration
+func cacheStore(cfg *envcfg.EnvCfg) (cache.Store, error) {
+	if cfg.CacheBackend == "disk" {
+		// Keep expired responses for as long as they may still be served stale
+		retain := cfg.CacheStaleIfError
+		if cfg.CacheStaleWhileRevalidate > retain {
+			retain = cfg.CacheStaleWhileRevalidate
+		}
+
+		return cache.OpenDiskStore(cfg.CacheDir, cache.DiskOptions{
+			MaxBytes:        cfg.CacheMaxBytes,
+			Retain:          retain,
+			CompactInterval: 10 * time.Minute,
+		})
+	}
+	return cache.NewLRU(cfg.CacheMaxBytes), nil
 }
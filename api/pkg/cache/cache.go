@@ -0,0 +1,240 @@
+// Package cache provides a kclient.GroceryClient decorator that keeps responses in a Store for a while, so
+// repeated searches and lookups are answered without calling the Kroger API.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Longest a background refresh of a stale response may take
+const revalidateTimeout = time.Minute
+
+// Controls how long responses are cached
+type Options struct {
+	// How long locations, chains and departments are cached. Store data rarely changes so this can be long.
+	// Zero disables caching them.
+	LocationTtl time.Duration
+	// How long product searches and lookups are cached. They include prices and stock levels so this should be
+	// short. Zero disables caching them.
+	ProductTtl time.Duration
+	// How long after a response expires it is still served when the Kroger API fails or times out
+	StaleIfError time.Duration
+	// How long after a response expires it is served straight away while a fresh one is fetched in the
+	// background, so popular queries stay fresh without callers waiting on Kroger
+	StaleWhileRevalidate time.Duration
+}
+
+// Gets the options used when none are given: a day for store data and five minutes for products, with
+// expired responses served for up to a day when the Kroger API fails
+func DefaultOptions() Options {
+	return Options{
+		LocationTtl:          24 * time.Hour,
+		ProductTtl:           5 * time.Minute,
+		StaleIfError:         24 * time.Hour,
+		StaleWhileRevalidate: time.Minute,
+	}
+}
+
+// GroceryClient that answers from its Store while a response is fresh and from the wrapped client otherwise.
+// Errors are never cached. Values are stored serialized, so callers may modify the responses they get.
+type CachedClient struct {
+	client kclient.GroceryClient
+	store  Store
+	opts   Options
+	now    func() time.Time
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+	background   sync.WaitGroup
+}
+
+var _ kclient.GroceryClient = (*CachedClient)(nil)
+
+// Creates a CachedClient that wraps client and keeps responses in store
+func New(client kclient.GroceryClient, store Store, opts Options) *CachedClient {
+	return &CachedClient{
+		client:       client,
+		store:        store,
+		opts:         opts,
+		now:          time.Now,
+		revalidating: make(map[string]bool),
+	}
+}
+
+// Waits for background refreshes of stale responses to finish
+func (cached *CachedClient) Wait() {
+	cached.background.Wait()
+}
+
+// Checks whether a failure is one that a stale response can stand in for. Invalid requests and missing
+// resources would fail the same way again, and a cancelled request has no one waiting for the response.
+func serveStaleOn(err error) bool {
+	switch {
+	case errors.Is(err, kclient.ErrValidation), errors.Is(err, kclient.ErrNotFound), errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+// Gets a response from the store if it is fresh, and otherwise from get, storing what it returns for ttl.
+// Expired responses are served while they are refreshed in the background for the StaleWhileRevalidate
+// period, and in place of errors for the StaleIfError period. The outcome is recorded in the Status attached
+// to ctx, if any.
+func fetch[T any](cached *CachedClient, ctx context.Context, key string, ttl time.Duration, get func(ctx context.Context) (*T, error)) (*T, error) {
+	status := StatusFrom(ctx)
+	if ttl <= 0 {
+		status.recordMiss()
+		return get(ctx)
+	}
+
+	now := cached.now()
+	entry, found := cached.store.Get(key)
+	if found {
+		age := now.Sub(entry.StoredAt)
+		switch {
+		case now.Before(entry.Expires):
+			if resp, err := decodeEntry[T](entry, false, age); err == nil {
+				status.recordHit(age, "")
+				return resp, nil
+			}
+		case now.Before(entry.Expires.Add(cached.opts.StaleWhileRevalidate)):
+			if resp, err := decodeEntry[T](entry, true, age); err == nil {
+				status.recordHit(age, WarningStale)
+				revalidate(cached, key, ttl, get)
+				return resp, nil
+			}
+		}
+	}
+
+	resp, err := get(ctx)
+	if err != nil {
+		if found && serveStaleOn(err) && now.Before(entry.Expires.Add(cached.opts.StaleIfError)) {
+			age := now.Sub(entry.StoredAt)
+			if resp, decodeErr := decodeEntry[T](entry, true, age); decodeErr == nil {
+				status.recordHit(age, WarningRevalidationFailed)
+				return resp, nil
+			}
+		}
+		status.recordMiss()
+		return nil, err
+	}
+
+	status.recordMiss()
+	cached.put(key, ttl, now, resp)
+	return resp, nil
+}
+
+// Refreshes a stale response in the background unless a refresh for it is already running
+func revalidate[T any](cached *CachedClient, key string, ttl time.Duration, get func(ctx context.Context) (*T, error)) {
+	cached.mu.Lock()
+	if cached.revalidating[key] {
+		cached.mu.Unlock()
+		return
+	}
+	cached.revalidating[key] = true
+	cached.mu.Unlock()
+
+	cached.background.Add(1)
+	go func() {
+		defer cached.background.Done()
+		defer func() {
+			cached.mu.Lock()
+			delete(cached.revalidating, key)
+			cached.mu.Unlock()
+		}()
+
+		// The request that found the stale response may finish first, so the refresh gets its own context
+		ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+		defer cancel()
+
+		if resp, err := get(ctx); err == nil {
+			cached.put(key, ttl, cached.now(), resp)
+		}
+	}()
+}
+
+// Stores a response fetched at the given time
+func (cached *CachedClient) put(key string, ttl time.Duration, now time.Time, resp interface{}) {
+	if value, err := json.Marshal(resp); err == nil {
+		cached.store.Set(key, Entry{Value: value, StoredAt: now, Expires: now.Add(ttl)})
+	}
+}
+
+// Decodes a stored response. Stale responses have the stale flag and their age in seconds set in their meta.
+func decodeEntry[T any](entry Entry, stale bool, age time.Duration) (*T, error) {
+	var resp T
+	if err := json.Unmarshal(entry.Value, &resp); err != nil {
+		return nil, err
+	}
+
+	if stale {
+		var freshness struct {
+			Meta models.Freshness `json:"meta"`
+		}
+		freshness.Meta = models.Freshness{Stale: true, Age: int(age / time.Second)}
+		patch, err := json.Marshal(freshness)
+		if err != nil {
+			return nil, err
+		}
+		// Unmarshalling only sets the fields in the patch, leaving the rest of the meta as it was
+		if err := json.Unmarshal(patch, &resp); err != nil {
+			return nil, err
+		}
+	}
+	return &resp, nil
+}
+
+func (cached *CachedClient) GetLocations(ctx context.Context, query kclient.LocationQuery) (*models.LocationsResponse, error) {
+	return fetch(cached, ctx, kclient.RequestKey("GetLocations", query), cached.opts.LocationTtl, func(ctx context.Context) (*models.LocationsResponse, error) {
+		return cached.client.GetLocations(ctx, query)
+	})
+}
+
+func (cached *CachedClient) GetLocation(ctx context.Context, locationId string) (*models.LocationResponse, error) {
+	return fetch(cached, ctx, kclient.RequestKey("GetLocation", locationId), cached.opts.LocationTtl, func(ctx context.Context) (*models.LocationResponse, error) {
+		return cached.client.GetLocation(ctx, locationId)
+	})
+}
+
+func (cached *CachedClient) GetProducts(ctx context.Context, query kclient.ProductQuery) (*models.ProductsResponse, error) {
+	return fetch(cached, ctx, kclient.RequestKey("GetProducts", query), cached.opts.ProductTtl, func(ctx context.Context) (*models.ProductsResponse, error) {
+		return cached.client.GetProducts(ctx, query)
+	})
+}
+
+func (cached *CachedClient) GetProduct(ctx context.Context, productId string, locationId string) (*models.ProductResponse, error) {
+	return fetch(cached, ctx, kclient.RequestKey("GetProduct", productId, locationId), cached.opts.ProductTtl, func(ctx context.Context) (*models.ProductResponse, error) {
+		return cached.client.GetProduct(ctx, productId, locationId)
+	})
+}
+
+func (cached *CachedClient) GetChains(ctx context.Context) (*models.ChainsResponse, error) {
+	return fetch(cached, ctx, kclient.RequestKey("GetChains"), cached.opts.LocationTtl, func(ctx context.Context) (*models.ChainsResponse, error) {
+		return cached.client.GetChains(ctx)
+	})
+}
+
+func (cached *CachedClient) GetChain(ctx context.Context, name string) (*models.ChainResponse, error) {
+	return fetch(cached, ctx, kclient.RequestKey("GetChain", name), cached.opts.LocationTtl, func(ctx context.Context) (*models.ChainResponse, error) {
+		return cached.client.GetChain(ctx, name)
+	})
+}
+
+func (cached *CachedClient) GetDepartments(ctx context.Context) (*models.DepartmentsResponse, error) {
+	return fetch(cached, ctx, kclient.RequestKey("GetDepartments"), cached.opts.LocationTtl, func(ctx context.Context) (*models.DepartmentsResponse, error) {
+		return cached.client.GetDepartments(ctx)
+	})
+}
+
+func (cached *CachedClient) GetDepartment(ctx context.Context, departmentId string) (*models.DepartmentResponse, error) {
+	return fetch(cached, ctx, kclient.RequestKey("GetDepartment", departmentId), cached.opts.LocationTtl, func(ctx context.Context) (*models.DepartmentResponse, error) {
+		return cached.client.GetDepartment(ctx, departmentId)
+	})
+}
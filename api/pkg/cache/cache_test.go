@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
+	"github.com/jondysinger/grocery-data/api/pkg/kclient/kclienttest"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Creates a CachedClient over a fake with a few products and a clock that can be moved forward
+func newTestClient() (*CachedClient, *kclienttest.Fake, *time.Time) {
+	fake := kclienttest.New()
+	fake.Products = []models.Product{
+		{ProductId: "0001111041700", Brand: "Kroger", Description: "Kroger 2% Reduced Fat Milk"},
+		{ProductId: "0004138703023", Brand: "Darigold", Description: "Darigold Whole Milk"},
+	}
+	var store models.Location
+	store.LocationId = "70100393"
+	fake.Locations = []models.Location{store}
+
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	client := New(fake, NewLRU(1<<20), Options{LocationTtl: time.Hour, ProductTtl: time.Minute})
+	client.now = func() time.Time { return now }
+	return client, fake, &now
+}
+
+func TestCachedClientHit(t *testing.T) {
+	client, fake, _ := newTestClient()
+	query := kclient.ProductQuery{Term: "milk", LocationId: "70100393"}
+
+	for i := 0; i < 3; i++ {
+		products, err := client.GetProducts(context.Background(), query)
+		if err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		} else if len(products.Data) != 2 {
+			t.Fatalf("expected 2 products but got %d", len(products.Data))
+		}
+	}
+
+	if calls := fake.Calls(kclienttest.GetProducts); len(calls) != 1 {
+		t.Errorf("expected 1 call to the client but got %d", len(calls))
+	}
+}
+
+func TestCachedClientKeysByQuery(t *testing.T) {
+	client, fake, _ := newTestClient()
+	queries := []kclient.ProductQuery{
+		{Term: "milk"},
+		{Term: "milk", LocationId: "70100393"},
+		{Term: "milk", Limit: 1},
+		{Term: "milk", Start: 1},
+		{Term: "milk", Brand: "Kroger"},
+	}
+
+	for _, query := range queries {
+		if _, err := client.GetProducts(context.Background(), query); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+	}
+
+	if calls := fake.Calls(kclienttest.GetProducts); len(calls) != len(queries) {
+		t.Errorf("expected %d calls to the client but got %d", len(queries), len(calls))
+	}
+}
+
+func TestCachedClientTtl(t *testing.T) {
+	client, fake, now := newTestClient()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetProducts(context.Background(), kclient.ProductQuery{Term: "milk"}); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+		if _, err := client.GetLocation(context.Background(), "70100393"); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+
+		// Products expire after a minute but locations are kept for an hour
+		*now = now.Add(2 * time.Minute)
+	}
+
+	if calls := fake.Calls(kclienttest.GetProducts); len(calls) != 2 {
+		t.Errorf("expected 2 product calls but got %d", len(calls))
+	}
+	if calls := fake.Calls(kclienttest.GetLocation); len(calls) != 1 {
+		t.Errorf("expected 1 location call but got %d", len(calls))
+	}
+}
+
+func TestCachedClientDoesNotCacheErrors(t *testing.T) {
+	client, fake, _ := newTestClient()
+	fake.FailNext(kclienttest.GetProducts, errors.New("boom"))
+
+	if _, err := client.GetProducts(context.Background(), kclient.ProductQuery{Term: "milk"}); err == nil {
+		t.Fatal("expected err but got none")
+	}
+	if _, err := client.GetProducts(context.Background(), kclient.ProductQuery{Term: "milk"}); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+}
+
+func TestCachedClientReturnsCopies(t *testing.T) {
+	client, _, _ := newTestClient()
+
+	products, err := client.GetProducts(context.Background(), kclient.ProductQuery{Term: "milk"})
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	products.Data = products.Data[:0]
+
+	products, err = client.GetProducts(context.Background(), kclient.ProductQuery{Term: "milk"})
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if len(products.Data) != 2 {
+		t.Errorf("expected the cached response to be unaffected but got %d products", len(products.Data))
+	}
+}
+
+func TestCachedClientStatus(t *testing.T) {
+	client, _, _ := newTestClient()
+	testCases := []struct {
+		name     string
+		calls    int
+		expected string
+	}{
+		{"no calls", 0, ""},
+		{"miss", 1, Miss},
+		{"hit", 1, Hit},
+		{"several hits", 2, Hit},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, status := WithStatus(context.Background())
+			for i := 0; i < tc.calls; i++ {
+				if _, err := client.GetChains(ctx); err != nil {
+					t.Fatalf("expected success but got error, %v", err)
+				}
+			}
+			if header := status.Header(); header != tc.expected {
+				t.Errorf("expected %q but got %q", tc.expected, header)
+			}
+		})
+	}
+}
+
+func TestCachedClientDisabled(t *testing.T) {
+	fake := kclienttest.New()
+	client := New(fake, NewLRU(1<<20), Options{})
+
+	ctx, status := WithStatus(context.Background())
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetChains(ctx); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+	}
+
+	if calls := fake.Calls(kclienttest.GetChains); len(calls) != 2 {
+		t.Errorf("expected 2 calls to the client but got %d", len(calls))
+	} else if status.Header() != Miss {
+		t.Errorf("expected %s but got %s", Miss, status.Header())
+	}
+}
+
+func TestCachedClientStaleIfError(t *testing.T) {
+	client, fake, now := newTestClient()
+	client.opts.StaleIfError = time.Hour
+	query := kclient.ProductQuery{Term: "milk"}
+
+	if _, err := client.GetProducts(context.Background(), query); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	*now = now.Add(2 * time.Minute)
+
+	testCases := []struct {
+		name      string
+		err       error
+		expectErr bool
+	}{
+		{"upstream error", &kclient.APIError{StatusCode: 503}, false},
+		{"timeout", context.DeadlineExceeded, false},
+		{"quota exceeded", &kclient.LimitError{Endpoint: "products", Daily: true}, false},
+		{"not found", &kclient.APIError{StatusCode: 404}, true},
+		{"validation", &kclient.ValidationError{Message: "bad"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake.FailNext(kclienttest.GetProducts, tc.err)
+			ctx, status := WithStatus(context.Background())
+
+			products, err := client.GetProducts(ctx, query)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatal("expected err but got none")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("expected success but got error, %v", err)
+			}
+
+			if !products.Meta.Stale || products.Meta.Age != 120 || len(products.Data) != 2 {
+				t.Errorf("expected a stale response aged 120s but got %+v", products.Meta.Freshness)
+			}
+			if status.Header() != Stale || len(status.Warnings()) != 1 || status.Warnings()[0] != WarningRevalidationFailed {
+				t.Errorf("unexpected status %s with warnings %v", status.Header(), status.Warnings())
+			}
+		})
+	}
+
+	// Past the stale period the error is returned
+	*now = now.Add(2 * time.Hour)
+	fake.FailNext(kclienttest.GetProducts, &kclient.APIError{StatusCode: 503})
+	if _, err := client.GetProducts(context.Background(), query); err == nil {
+		t.Error("expected err but got none")
+	}
+}
+
+func TestCachedClientStaleWhileRevalidate(t *testing.T) {
+	client, fake, now := newTestClient()
+	client.opts.StaleWhileRevalidate = 5 * time.Minute
+	query := kclient.ProductQuery{Term: "milk"}
+
+	if _, err := client.GetProducts(context.Background(), query); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	*now = now.Add(2 * time.Minute)
+
+	// The expired response is served straight away and refreshed in the background
+	ctx, status := WithStatus(context.Background())
+	products, err := client.GetProducts(ctx, query)
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if !products.Meta.Stale {
+		t.Error("expected a stale response")
+	} else if status.Header() != Stale || status.Warnings()[0] != WarningStale {
+		t.Errorf("unexpected status %s with warnings %v", status.Header(), status.Warnings())
+	}
+	client.Wait()
+
+	if calls := fake.Calls(kclienttest.GetProducts); len(calls) != 2 {
+		t.Fatalf("expected 2 calls to the client but got %d", len(calls))
+	}
+
+	ctx, status = WithStatus(context.Background())
+	if products, err = client.GetProducts(ctx, query); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if products.Meta.Stale || status.Header() != Hit {
+		t.Errorf("expected a fresh hit but got status %s", status.Header())
+	}
+}
@@ -0,0 +1,318 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Extension of the files entries are stored in
+const diskEntryExt = ".entry"
+
+// Extension added to an entry file's name for the temporary file it is written through
+const diskTempExt = ".tmp"
+
+// Header written on the first line of an entry file, followed by the raw value
+type diskHeader struct {
+	Key      string    `json:"key"`
+	StoredAt time.Time `json:"storedAt"`
+	Expires  time.Time `json:"expires"`
+}
+
+// What the DiskStore keeps in memory about each entry file
+type diskMeta struct {
+	file     string
+	size     int64
+	expires  time.Time
+	lastUsed time.Time
+}
+
+// Controls a DiskStore
+type DiskOptions struct {
+	// Largest total size of the entry files. The least recently used entries are deleted to stay within it.
+	MaxBytes int64
+	// How long entries are kept after they expire before compaction deletes them, so they can still be served
+	// when the Kroger API is failing. Zero deletes them as soon as they expire.
+	Retain time.Duration
+	// How often expired entries are deleted. Zero only compacts when the store is opened.
+	CompactInterval time.Duration
+}
+
+// Store that keeps each entry in its own file in a directory, so the cache survives restarts. An index of
+// the files is kept in memory, with recency reset to the files' modification times when the store is opened.
+// A DiskStore is safe for concurrent use within a process, but a directory must not be shared between
+// processes.
+type DiskStore struct {
+	dir  string
+	opts DiskOptions
+	now  func() time.Time
+
+	mu    sync.Mutex
+	size  int64
+	index map[string]*diskMeta
+	done  chan struct{}
+	once  sync.Once
+}
+
+var _ Store = (*DiskStore)(nil)
+
+// Opens the store in dir, creating the directory if needed, and deletes expired entries left from before
+func OpenDiskStore(dir string, opts DiskOptions) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	store := &DiskStore{
+		dir:   dir,
+		opts:  opts,
+		now:   time.Now,
+		index: make(map[string]*diskMeta),
+		done:  make(chan struct{}),
+	}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	store.Compact()
+
+	if opts.CompactInterval > 0 {
+		go store.compactEvery(opts.CompactInterval)
+	}
+	return store, nil
+}
+
+// Rebuilds the index from the entry files in the directory, removing entry files that cannot be read and
+// temporary files left from interrupted writes. Other files are left alone in case the directory is shared.
+func (store *DiskStore) load() error {
+	files, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %v", err)
+	}
+
+	for _, info := range files {
+		path := filepath.Join(store.dir, info.Name())
+		if info.IsDir() {
+			continue
+		} else if strings.Contains(info.Name(), diskEntryExt+diskTempExt) {
+			// Left over from a write that was interrupted
+			os.Remove(path)
+			continue
+		} else if !strings.HasSuffix(info.Name(), diskEntryExt) {
+			continue
+		}
+
+		header, _, err := readEntryFile(path)
+		if err != nil {
+			os.Remove(path)
+			continue
+		}
+		store.index[header.Key] = &diskMeta{
+			file:     info.Name(),
+			size:     info.Size(),
+			expires:  header.Expires,
+			lastUsed: info.ModTime(),
+		}
+		store.size += info.Size()
+	}
+	return nil
+}
+
+// Gets an entry from its file and marks it as recently used
+func (store *DiskStore) Get(key string) (Entry, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	meta, ok := store.index[key]
+	if !ok {
+		return Entry{}, false
+	}
+
+	header, value, err := readEntryFile(filepath.Join(store.dir, meta.file))
+	if err != nil || header.Key != key {
+		store.remove(key)
+		return Entry{}, false
+	}
+
+	meta.lastUsed = store.now()
+	return Entry{Value: value, StoredAt: header.StoredAt, Expires: header.Expires}, true
+}
+
+// Writes an entry to its file and deletes the least recently used entries until the store is within its
+// size. Failures are logged since a cache that cannot be written to still works, only with more misses.
+func (store *DiskStore) Set(key string, entry Entry) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.index[key]; ok {
+		store.remove(key)
+	}
+
+	file := fileName(key)
+	size, err := writeEntryFile(filepath.Join(store.dir, file), diskHeader{Key: key, StoredAt: entry.StoredAt, Expires: entry.Expires}, entry.Value)
+	if err != nil {
+		log.Printf("failed to write cache entry: %v", err)
+		return
+	}
+
+	store.index[key] = &diskMeta{file: file, size: size, expires: entry.Expires, lastUsed: store.now()}
+	store.size += size
+	if store.opts.MaxBytes > 0 && size > store.opts.MaxBytes {
+		// Larger than the whole store, so keep the other entries rather than evicting them all for it
+		store.remove(key)
+		return
+	}
+	store.evict()
+}
+
+// Deletes entries that expired longer ago than the Retain option allows
+func (store *DiskStore) Compact() {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	cutoff := store.now().Add(-store.opts.Retain)
+	for key, meta := range store.index {
+		if meta.expires.Before(cutoff) {
+			store.remove(key)
+		}
+	}
+	store.evict()
+}
+
+// Gets the number of entries held
+func (store *DiskStore) Len() int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return len(store.index)
+}
+
+// Gets the total size of the entry files
+func (store *DiskStore) Size() int64 {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.size
+}
+
+// Stops background compaction. The entry files are kept.
+func (store *DiskStore) Close() error {
+	store.once.Do(func() {
+		close(store.done)
+	})
+	return nil
+}
+
+// Compacts the store on an interval until it is closed
+func (store *DiskStore) compactEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			store.Compact()
+		case <-store.done:
+			return
+		}
+	}
+}
+
+// Deletes the least recently used entries until the store is within its size. Must be called with
+// store.mu held.
+func (store *DiskStore) evict() {
+	if store.opts.MaxBytes <= 0 || store.size <= store.opts.MaxBytes {
+		return
+	}
+
+	keys := make([]string, 0, len(store.index))
+	for key := range store.index {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return store.index[keys[i]].lastUsed.Before(store.index[keys[j]].lastUsed)
+	})
+
+	for _, key := range keys {
+		if store.size <= store.opts.MaxBytes {
+			break
+		}
+		store.remove(key)
+	}
+}
+
+// Deletes an entry and its file. Must be called with store.mu held.
+func (store *DiskStore) remove(key string) {
+	meta, ok := store.index[key]
+	if !ok {
+		return
+	}
+
+	if err := os.Remove(filepath.Join(store.dir, meta.file)); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to delete cache entry: %v", err)
+	}
+	delete(store.index, key)
+	store.size -= meta.size
+}
+
+// Gets the name of the file an entry is stored in. Keys contain characters that are not allowed in file
+// names so they are hashed.
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + diskEntryExt
+}
+
+// Writes an entry file through a temporary file so a crash never leaves a partially written entry behind,
+// and gets the size of the file
+func writeEntryFile(path string, header diskHeader, value []byte) (int64, error) {
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(headerJson)
+	buf.WriteByte('\n')
+	buf.Write(value)
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+diskTempExt+"*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return 0, err
+	} else if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return int64(buf.Len()), nil
+}
+
+// Reads an entry file's header and value
+func readEntryFile(path string) (diskHeader, []byte, error) {
+	var header diskHeader
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return header, nil, err
+	}
+
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		return header, nil, fmt.Errorf("cache entry '%s' has no header", path)
+	}
+	if err := json.Unmarshal(data[:end], &header); err != nil {
+		return header, nil, fmt.Errorf("failed to deserialize cache entry '%s': %v", path, err)
+	}
+	return header, data[end+1:], nil
+}
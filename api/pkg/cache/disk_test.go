@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func openTestDiskStore(t *testing.T, dir string, opts DiskOptions) *DiskStore {
+	store, err := OpenDiskStore(dir, opts)
+	if err != nil {
+		t.Fatalf("error during store setup, %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestDiskStoreSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	store := openTestDiskStore(t, dir, DiskOptions{})
+	store.Set(`GetProducts:[{"Term":"milk"}]`, Entry{Value: []byte(`{"data":[]}`), Expires: expires})
+	store.Close()
+
+	store = openTestDiskStore(t, dir, DiskOptions{})
+	entry, ok := store.Get(`GetProducts:[{"Term":"milk"}]`)
+	if !ok {
+		t.Fatal("expected entry to be loaded from disk")
+	} else if string(entry.Value) != `{"data":[]}` || !entry.Expires.Equal(expires) {
+		t.Errorf("unexpected entry %s expiring %v", entry.Value, entry.Expires)
+	}
+}
+
+func TestDiskStoreCompact(t *testing.T) {
+	store := openTestDiskStore(t, t.TempDir(), DiskOptions{Retain: time.Hour})
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	store.Set("fresh", Entry{Value: []byte("1"), Expires: now.Add(time.Minute)})
+	store.Set("stale", Entry{Value: []byte("2"), Expires: now.Add(-time.Minute)})
+	store.Set("old", Entry{Value: []byte("3"), Expires: now.Add(-2 * time.Hour)})
+	store.Compact()
+
+	// Expired entries are kept for the retain period
+	for key, expected := range map[string]bool{"fresh": true, "stale": true, "old": false} {
+		if _, ok := store.Get(key); ok != expected {
+			t.Errorf("expected entry %s cached to be %v", key, expected)
+		}
+	}
+}
+
+func TestDiskStoreMaxBytes(t *testing.T) {
+	store := openTestDiskStore(t, t.TempDir(), DiskOptions{})
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	expires := now.Add(time.Hour)
+
+	store.Set("a", Entry{Value: []byte("value"), Expires: expires})
+	entrySize := store.Size()
+	store.opts.MaxBytes = 3 * entrySize
+
+	for _, key := range []string{"b", "c"} {
+		now = now.Add(time.Second)
+		store.Set(key, Entry{Value: []byte("value"), Expires: expires})
+	}
+
+	// Using "a" makes "b" the least recently used
+	now = now.Add(time.Second)
+	store.Get("a")
+	now = now.Add(time.Second)
+	store.Set("d", Entry{Value: []byte("value"), Expires: expires})
+
+	if _, ok := store.Get("b"); ok {
+		t.Error("expected entry b to be evicted")
+	}
+	if store.Len() != 3 || store.Size() != 3*entrySize {
+		t.Errorf("expected 3 entries of %d bytes but got %d entries of %d bytes", 3*entrySize, store.Len(), store.Size())
+	}
+
+	files, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		t.Fatalf("failed to read cache directory, %v", err)
+	} else if len(files) != 3 {
+		t.Errorf("expected 3 entry files but got %d", len(files))
+	}
+}
+
+func TestDiskStoreRemovesBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"corrupt" + diskEntryExt, "interrupted.entry.tmp123", "notes.txt", "backup.entry.bak"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("garbage"), 0644); err != nil {
+			t.Fatalf("failed to write file, %v", err)
+		}
+	}
+
+	store := openTestDiskStore(t, dir, DiskOptions{})
+	if store.Len() != 0 {
+		t.Errorf("expected no entries but got %d", store.Len())
+	}
+
+	// Only the store's own files are removed, in case the directory holds other files
+	files, _ := ioutil.ReadDir(dir)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"backup.entry.bak", "notes.txt"}) {
+		t.Errorf("expected only the other files to be kept but found %v", names)
+	}
+}
+
+func TestDiskStoreMissingFile(t *testing.T) {
+	store := openTestDiskStore(t, t.TempDir(), DiskOptions{})
+	store.Set("a", Entry{Value: []byte("value"), Expires: time.Now().Add(time.Hour)})
+
+	if err := os.Remove(filepath.Join(store.dir, fileName("a"))); err != nil {
+		t.Fatalf("failed to remove file, %v", err)
+	}
+	if _, ok := store.Get("a"); ok {
+		t.Error("expected entry with a missing file not to be returned")
+	} else if store.Len() != 0 || store.Size() != 0 {
+		t.Errorf("expected the entry to be dropped but got %d entries of %d bytes", store.Len(), store.Size())
+	}
+}
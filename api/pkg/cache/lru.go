@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// A cached value
+type Entry struct {
+	// The response serialized as JSON
+	Value []byte
+	// When the value was fetched
+	StoredAt time.Time
+	// When the value should no longer be served
+	Expires time.Time
+}
+
+// Storage for cached entries. A store may drop entries at any time, e.g. to stay within a size limit, but
+// must return expired entries it still holds since CachedClient decides whether they can be used.
+type Store interface {
+	Get(key string) (Entry, bool)
+	Set(key string, entry Entry)
+}
+
+// In-memory Store that evicts the least recently used entries once the total size of the keys and values
+// exceeds a limit. An LRU is safe for concurrent use.
+type LRU struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// Element of the LRU's recency list
+type lruItem struct {
+	key   string
+	entry Entry
+}
+
+var _ Store = (*LRU)(nil)
+
+// Creates an LRU that holds up to maxBytes of keys and values
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Gets an entry and marks it as recently used
+func (lru *LRU) Get(key string) (Entry, bool) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	elem, ok := lru.entries[key]
+	if !ok {
+		return Entry{}, false
+	}
+	lru.order.MoveToFront(elem)
+	return elem.Value.(*lruItem).entry, true
+}
+
+// Adds or replaces an entry and evicts the least recently used entries until the LRU is within its size. An
+// entry larger than the whole LRU is not stored.
+func (lru *LRU) Set(key string, entry Entry) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	if elem, ok := lru.entries[key]; ok {
+		lru.remove(elem)
+	}
+
+	size := itemSize(key, entry)
+	if size > lru.maxBytes {
+		return
+	}
+
+	lru.entries[key] = lru.order.PushFront(&lruItem{key: key, entry: entry})
+	lru.size += size
+	for lru.size > lru.maxBytes {
+		lru.remove(lru.order.Back())
+	}
+}
+
+// Gets the number of entries held
+func (lru *LRU) Len() int {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.order.Len()
+}
+
+// Gets the total size of the keys and values held
+func (lru *LRU) Size() int64 {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.size
+}
+
+// Removes an element. Must be called with lru.mu held.
+func (lru *LRU) remove(elem *list.Element) {
+	item := lru.order.Remove(elem).(*lruItem)
+	delete(lru.entries, item.key)
+	lru.size -= itemSize(item.key, item.entry)
+}
+
+// Gets the number of bytes an entry counts for
+func itemSize(key string, entry Entry) int64 {
+	return int64(len(key) + len(entry.Value))
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	// Each entry is a one byte key and a nine byte value
+	lru := NewLRU(30)
+	lru.Set("a", Entry{Value: []byte("123456789")})
+	lru.Set("b", Entry{Value: []byte("123456789")})
+	lru.Set("c", Entry{Value: []byte("123456789")})
+
+	// Using "a" makes "b" the least recently used
+	if _, ok := lru.Get("a"); !ok {
+		t.Fatal("expected entry a to be cached")
+	}
+	lru.Set("d", Entry{Value: []byte("123456789")})
+
+	if _, ok := lru.Get("b"); ok {
+		t.Error("expected entry b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := lru.Get(key); !ok {
+			t.Errorf("expected entry %s to be cached", key)
+		}
+	}
+	if lru.Len() != 3 || lru.Size() != 30 {
+		t.Errorf("expected 3 entries of 30 bytes but got %d entries of %d bytes", lru.Len(), lru.Size())
+	}
+}
+
+func TestLRUReplace(t *testing.T) {
+	lru := NewLRU(100)
+	lru.Set("a", Entry{Value: []byte("first")})
+	lru.Set("a", Entry{Value: []byte("second value")})
+
+	entry, ok := lru.Get("a")
+	if !ok || string(entry.Value) != "second value" {
+		t.Fatalf("expected the replaced value but got %q", entry.Value)
+	} else if lru.Len() != 1 || lru.Size() != 13 {
+		t.Errorf("expected 1 entry of 13 bytes but got %d entries of %d bytes", lru.Len(), lru.Size())
+	}
+}
+
+func TestLRUSkipsOversizedEntries(t *testing.T) {
+	lru := NewLRU(10)
+	lru.Set("a", Entry{Value: []byte("1234")})
+	lru.Set("b", Entry{Value: []byte("this value is too large")})
+
+	if _, ok := lru.Get("b"); ok {
+		t.Error("expected oversized entry not to be cached")
+	} else if _, ok := lru.Get("a"); !ok {
+		t.Error("expected existing entry to be kept")
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Values of the X-Cache response header
+const (
+	Hit   = "HIT"
+	Miss  = "MISS"
+	Stale = "STALE"
+)
+
+// Values of the Warning response header for stale responses
+const (
+	// The response is stale and being refreshed in the background
+	WarningStale = `110 - "Response is Stale"`
+	// The response is stale because refreshing it from the Kroger API failed
+	WarningRevalidationFailed = `111 - "Revalidation Failed"`
+)
+
+// Collects whether the calls made while handling a request were answered from the cache. A Status is safe
+// for concurrent use, so handlers that fan out calls can share one.
+type Status struct {
+	mu       sync.Mutex
+	hits     int
+	misses   int
+	stale    int
+	age      time.Duration
+	warnings []string
+}
+
+type statusKey struct{}
+
+// Attaches a new Status to ctx for a CachedClient to record its outcomes in
+func WithStatus(ctx context.Context) (context.Context, *Status) {
+	status := &Status{}
+	return context.WithValue(ctx, statusKey{}, status), status
+}
+
+// Gets the Status attached to ctx, or nil if there is none
+func StatusFrom(ctx context.Context) *Status {
+	status, _ := ctx.Value(statusKey{}).(*Status)
+	return status
+}
+
+// Records a call that went to the wrapped client. Does nothing on a nil Status.
+func (status *Status) recordMiss() {
+	if status == nil {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	status.misses++
+}
+
+// Records a call answered from the cache with a response of the given age, and the warning to give when the
+// response was stale. Does nothing on a nil Status.
+func (status *Status) recordHit(age time.Duration, warning string) {
+	if status == nil {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	if warning == "" {
+		status.hits++
+	} else {
+		status.stale++
+		if !containsString(status.warnings, warning) {
+			status.warnings = append(status.warnings, warning)
+		}
+	}
+	if age > status.age {
+		status.age = age
+	}
+}
+
+// Gets the X-Cache header value for the calls recorded: STALE when any call was answered with a stale
+// response, MISS when any other call went to the Kroger API, HIT when every call was answered from the cache
+// and an empty string when no cached call was made
+func (status *Status) Header() string {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	switch {
+	case status.stale > 0:
+		return Stale
+	case status.misses > 0:
+		return Miss
+	case status.hits > 0:
+		return Hit
+	}
+	return ""
+}
+
+// Gets the age of the oldest response served from the cache
+func (status *Status) Age() time.Duration {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	return status.age
+}
+
+// Gets the Warning header values for the stale responses served
+func (status *Status) Warnings() []string {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	return append([]string{}, status.warnings...)
+}
+
+// Checks whether the list contains the value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
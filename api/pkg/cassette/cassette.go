@@ -0,0 +1,273 @@
+// Package cassette provides an http.RoundTripper that records HTTP interactions to a file and replays them
+// later, so tests can run against real API responses without a network connection or credentials.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Placeholder written in place of credentials and tokens
+const Redacted = "REDACTED"
+
+// Whether a Recorder records new interactions or replays saved ones
+type Mode int
+
+const (
+	// Serves responses from the cassette file and fails requests that were not recorded
+	ModeReplay Mode = iota
+	// Sends requests to the real server and saves the interactions when Save is called
+	ModeRecord
+)
+
+// Headers that carry credentials and are never saved
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Matches access and refresh tokens in OAuth2 token response bodies
+var tokenPattern = regexp.MustCompile(`"(access_token|refresh_token)"\s*:\s*"[^"]*"`)
+
+// A recorded request
+type Request struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// A recorded response
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// A request and the response it received
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// The contents of a cassette file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Records or replays HTTP interactions. Requests are matched by method, path, query and body, ignoring the
+// scheme and host, so a cassette recorded against one server can be replayed with any base URL.
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+	secrets   []string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// Creates a Recorder for the cassette file at path. In replay mode the file is loaded and must exist. In
+// record mode requests are sent with the given transport, or http.DefaultTransport when it is nil. Any of
+// the given secrets, such as a client ID and secret, are replaced in the saved cassette.
+func New(path string, mode Mode, transport http.RoundTripper, secrets ...string) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	rec := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: transport,
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			rec.secrets = append(rec.secrets, secret)
+		}
+	}
+
+	if mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %v", err)
+		}
+		if err := json.Unmarshal(data, &rec.cassette); err != nil {
+			return nil, fmt.Errorf("failed to deserialize cassette '%s': %v", path, err)
+		}
+		rec.used = make([]bool, len(rec.cassette.Interactions))
+	}
+
+	return rec, nil
+}
+
+// Gets the recorder's mode
+func (rec *Recorder) Mode() Mode {
+	return rec.mode
+}
+
+// Records or replays a single request
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if rec.mode == ModeReplay {
+		return rec.replay(req, body)
+	}
+	return rec.record(req, body)
+}
+
+// Sends the request to the real server and remembers the interaction
+func (rec *Recorder) record(req *http.Request, body string) (*http.Response, error) {
+	res, err := rec.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			Url:    req.URL.String(),
+			Header: rec.scrubHeader(req.Header),
+			Body:   rec.scrub(body),
+		},
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     rec.scrubHeader(res.Header),
+			Body:       rec.scrub(tokenPattern.ReplaceAllString(string(resBody), fmt.Sprintf(`"$1":"%s"`, Redacted))),
+		},
+	}
+
+	rec.mu.Lock()
+	rec.cassette.Interactions = append(rec.cassette.Interactions, interaction)
+	rec.mu.Unlock()
+
+	return res, nil
+}
+
+// Answers the request with the first unused recorded interaction that matches it. Once every match has been
+// used the last one is replayed again, so repeated requests keep working.
+func (rec *Recorder) replay(req *http.Request, body string) (*http.Response, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	found := -1
+	for i, interaction := range rec.cassette.Interactions {
+		if !rec.matches(interaction.Request, req, body) {
+			continue
+		}
+		found = i
+		if !rec.used[i] {
+			break
+		}
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("cassette '%s' has no interaction for %s %s", rec.path, req.Method, req.URL)
+	}
+	rec.used[found] = true
+
+	recorded := rec.cassette.Interactions[found].Response
+	res := &http.Response{
+		StatusCode:    recorded.StatusCode,
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          ioutil.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}
+	if res.Header == nil {
+		res.Header = http.Header{}
+	}
+	return res, nil
+}
+
+// Checks whether a recorded request matches a live one
+func (rec *Recorder) matches(recorded Request, req *http.Request, body string) bool {
+	if recorded.Method != req.Method || recorded.Body != rec.scrub(body) {
+		return false
+	}
+
+	recordedUrl, err := url.Parse(recorded.Url)
+	if err != nil {
+		return false
+	}
+	return recordedUrl.Path == req.URL.Path && recordedUrl.Query().Encode() == req.URL.Query().Encode()
+}
+
+// Writes the recorded interactions to the cassette file. Does nothing in replay mode.
+func (rec *Recorder) Save() error {
+	if rec.mode != ModeRecord {
+		return nil
+	}
+
+	rec.mu.Lock()
+	data, err := json.MarshalIndent(rec.cassette, "", "  ")
+	rec.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(rec.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %v", err)
+	}
+	return nil
+}
+
+// Replaces the recorder's secrets in a string
+func (rec *Recorder) scrub(value string) string {
+	for _, secret := range rec.secrets {
+		value = strings.ReplaceAll(value, secret, Redacted)
+	}
+	return value
+}
+
+// Copies a header without credentials
+func (rec *Recorder) scrubHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+
+	scrubbed := make(http.Header, len(header))
+	for key, values := range header {
+		for _, value := range values {
+			scrubbed.Add(key, rec.scrub(value))
+		}
+	}
+	for _, key := range sensitiveHeaders {
+		if scrubbed.Get(key) != "" {
+			scrubbed.Set(key, Redacted)
+		}
+	}
+	return scrubbed
+}
+
+// Reads the request body and puts it back so the request can still be sent
+func readRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body: %v", err)
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return string(body), nil
+}
@@ -0,0 +1,271 @@
+// Package domain holds the stores and products the API serves, in a shape of our own rather than Kroger's, so
+// the upstream can change or be swapped without breaking clients. Upstream responses are turned into these types
+// by the mappers in kroger.go.
+package domain
+
+import (
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/money"
+)
+
+// Stock levels of an offer
+const (
+	StockHigh = "high"
+	StockLow  = "low"
+	StockOut  = "out"
+	// The stock level is not known, such as when the product was not looked up for a store
+	StockUnknown = ""
+)
+
+// How an offer is priced
+const (
+	SoldByUnit   = "unit"
+	SoldByWeight = "weight"
+)
+
+type Store struct {
+	Id          string       `json:"id"`
+	Chain       string       `json:"chain"`
+	Name        string       `json:"name"`
+	Phone       string       `json:"phone"`
+	Address     Address      `json:"address"`
+	Latitude    float64      `json:"latitude"`
+	Longitude   float64      `json:"longitude"`
+	Hours       Hours        `json:"hours"`
+	Departments []Department `json:"departments"`
+	// Whether the store is open, omitted when its hours are not known
+	OpenNow *bool `json:"openNow,omitempty"`
+	// When the store next closes if it is open, in the store's timezone
+	ClosesAt *time.Time `json:"closesAt,omitempty"`
+}
+
+type Address struct {
+	Line1   string `json:"line1"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	ZipCode string `json:"zipCode"`
+	County  string `json:"county"`
+}
+
+// Weekly opening hours of a store
+type Hours struct {
+	// IANA timezone name such as "America/Los_Angeles", blank when not known
+	Timezone string `json:"timezone"`
+	// The store never closes
+	Open24 bool `json:"open24"`
+	// Hours for each day the store opens, from Monday to Sunday
+	Days []DayHours `json:"days"`
+}
+
+type DayHours struct {
+	// Lower case day of the week, e.g. "monday"
+	Day string `json:"day"`
+	// Local times like "06:00". A close at or before the open time is after midnight.
+	Open   string `json:"open,omitempty"`
+	Close  string `json:"close,omitempty"`
+	Open24 bool   `json:"open24"`
+}
+
+type Department struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Chain struct {
+	Name            string   `json:"name"`
+	DivisionNumbers []string `json:"divisionNumbers"`
+}
+
+type Product struct {
+	Id            string   `json:"id"`
+	Upc           string   `json:"upc"`
+	Brand         string   `json:"brand"`
+	Description   string   `json:"description"`
+	Categories    []string `json:"categories"`
+	CountryOrigin string   `json:"countryOrigin"`
+	// Storage temperature such as "Refrigerated" or "Frozen"
+	Temperature string  `json:"temperature"`
+	Images      []Image `json:"images"`
+	// Each way the product is sold, such as a size, with its price and stock at a store
+	Offers []Offer `json:"offers"`
+	// Where the product is shelved in the store, in walking order. Only known when the product was looked up
+	// for a store.
+	ShelfLocations []ShelfLocation `json:"shelfLocations"`
+}
+
+type Image struct {
+	Perspective string `json:"perspective"`
+	// Size name such as "thumbnail", "medium" or "large"
+	Size    string `json:"size"`
+	Url     string `json:"url"`
+	Default bool   `json:"default"`
+}
+
+type Offer struct {
+	ItemId string `json:"itemId"`
+	// Package size as given by the upstream, e.g. "1 gal"
+	Size string `json:"size"`
+	// SoldByUnit or SoldByWeight. Prices of items sold by weight are per pound.
+	SoldBy string `json:"soldBy"`
+	// Price a shopper pays, counting promotions. Prices are omitted when the product was not looked up for a
+	// store.
+	Price        *money.Money `json:"price,omitempty"`
+	RegularPrice *money.Money `json:"regularPrice,omitempty"`
+	// Price per ounce, fluid ounce or item, omitted when the price or size is not known
+	UnitPrice   *UnitPrice  `json:"unitPrice,omitempty"`
+	StockLevel  string      `json:"stockLevel"`
+	InStock     bool        `json:"inStock"`
+	Fulfillment Fulfillment `json:"fulfillment"`
+}
+
+type UnitPrice struct {
+	Price money.Money `json:"price"`
+	// Unit the price is for: "oz", "fl oz" or "ct"
+	Unit string `json:"unit"`
+}
+
+// Ways an offer can reach the shopper
+type Fulfillment struct {
+	Curbside   bool `json:"curbside"`
+	Delivery   bool `json:"delivery"`
+	InStore    bool `json:"inStore"`
+	ShipToHome bool `json:"shipToHome"`
+}
+
+// Where a product is shelved in a store. Numbers that are not known are zero.
+type ShelfLocation struct {
+	// Aisle number, zero for a department outside the numbered aisles
+	Aisle int `json:"aisle"`
+	// Department or aisle name such as "Dairy"
+	Department string `json:"department"`
+	// "left" or "right" when facing down the aisle
+	Side string `json:"side,omitempty"`
+	Bay  int    `json:"bay"`
+	// Shelf counted from the bottom, starting at 1
+	Shelf    int `json:"shelf"`
+	Position int `json:"position"`
+	Facings  int `json:"facings"`
+	// Sorts locations into walking order
+	SortKey string `json:"sortKey"`
+	// Directions such as "Aisle 12, left side, bay 4, 3rd shelf from bottom"
+	Directions string `json:"directions"`
+}
+
+// Gets the product's first offer and shelf location for comparing it across stores
+func (product Product) Compared() ComparedProduct {
+	compared := ComparedProduct{
+		Id:          product.Id,
+		Brand:       product.Brand,
+		Description: product.Description,
+	}
+	if len(product.Offers) > 0 {
+		offer := product.Offers[0]
+		compared.Size = offer.Size
+		compared.Price, compared.RegularPrice = offer.Price, offer.RegularPrice
+		compared.UnitPrice = offer.UnitPrice
+		compared.StockLevel, compared.InStock = offer.StockLevel, offer.InStock
+	}
+	if len(product.ShelfLocations) > 0 {
+		compared.Aisle = product.ShelfLocations[0].Directions
+	}
+	return compared
+}
+
+// Gets the first offer's price per unit, or nil when it is not known
+func (product Product) UnitPrice() *UnitPrice {
+	if len(product.Offers) == 0 {
+		return nil
+	}
+	return product.Offers[0].UnitPrice
+}
+
+// Details about a response that are not part of its data
+type Meta struct {
+	// Only set on lists that are paged
+	Pagination *Pagination `json:"pagination,omitempty"`
+	Warnings   []string    `json:"warnings,omitempty"`
+	// Set when the response was served from the cache after it expired
+	Stale bool `json:"stale,omitempty"`
+	// Seconds since a stale response was fetched from the upstream
+	Age int `json:"age,omitempty"`
+}
+
+type Pagination struct {
+	Total int `json:"total"`
+	Start int `json:"start"`
+	Limit int `json:"limit"`
+}
+
+type StoresResponse struct {
+	Data []Store `json:"data"`
+	Meta Meta    `json:"meta"`
+}
+
+type StoreResponse struct {
+	Data Store `json:"data"`
+	Meta Meta  `json:"meta"`
+}
+
+type ProductsResponse struct {
+	Data []Product `json:"data"`
+	Meta Meta      `json:"meta"`
+}
+
+type ProductResponse struct {
+	Data Product `json:"data"`
+	Meta Meta    `json:"meta"`
+}
+
+type ChainsResponse struct {
+	Data []Chain `json:"data"`
+	Meta Meta    `json:"meta"`
+}
+
+type ChainResponse struct {
+	Data Chain `json:"data"`
+	Meta Meta  `json:"meta"`
+}
+
+type DepartmentsResponse struct {
+	Data []Department `json:"data"`
+	Meta Meta         `json:"meta"`
+}
+
+type DepartmentResponse struct {
+	Data Department `json:"data"`
+	Meta Meta       `json:"meta"`
+}
+
+// One store's results when a product search is compared across stores
+type StoreComparison struct {
+	StoreId string  `json:"storeId"`
+	Chain   string  `json:"chain"`
+	Name    string  `json:"name"`
+	Address Address `json:"address"`
+	// Lowest price of the in-stock products, counting promotions. Omitted when nothing is in stock.
+	BestPrice *money.Money      `json:"bestPrice,omitempty"`
+	Products  []ComparedProduct `json:"products"`
+	// Set when the store's products could not be retrieved
+	Error string `json:"error,omitempty"`
+}
+
+// A product's first offer at a store and where to find it, flattened for comparing stores side by side
+type ComparedProduct struct {
+	Id          string `json:"id"`
+	Brand       string `json:"brand"`
+	Description string `json:"description"`
+	Size        string `json:"size"`
+	// Price a shopper pays, counting promotions, omitted when not known
+	Price        *money.Money `json:"price,omitempty"`
+	RegularPrice *money.Money `json:"regularPrice,omitempty"`
+	UnitPrice    *UnitPrice   `json:"unitPrice,omitempty"`
+	StockLevel   string       `json:"stockLevel"`
+	InStock      bool         `json:"inStock"`
+	// Directions to the first shelf location, blank when not known
+	Aisle string `json:"aisle"`
+}
+
+type CompareResponse struct {
+	Data []StoreComparison `json:"data"`
+}
@@ -0,0 +1,271 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+	"github.com/jondysinger/grocery-data/api/pkg/unitsize"
+)
+
+// Kroger's stock levels
+const (
+	krogerStockHigh = "HIGH"
+	krogerStockLow  = "LOW"
+	krogerStockOut  = "TEMPORARILY_OUT_OF_STOCK"
+)
+
+// Maps a Kroger location to a store, working out whether it is open at the given time
+func StoreFromKroger(location models.Location, now time.Time) Store {
+	store := Store{
+		Id:    location.LocationId,
+		Chain: location.Chain,
+		Name:  location.Name,
+		Phone: location.Phone,
+		Address: Address{
+			Line1:   location.Address.AddressLine1,
+			City:    location.Address.City,
+			State:   location.Address.State,
+			ZipCode: location.Address.ZipCode,
+			County:  location.Address.County,
+		},
+		Latitude:    coordinateFromKroger(location.Geolocation.Latitude),
+		Longitude:   coordinateFromKroger(location.Geolocation.Longitude),
+		Hours:       hoursFromKroger(location.Hours),
+		Departments: make([]Department, 0, len(location.Departments)),
+	}
+	for _, department := range location.Departments {
+		store.Departments = append(store.Departments, DepartmentFromKroger(department))
+	}
+
+	if location.Hours.Known() {
+		open := location.Hours.IsOpenAt(now)
+		store.OpenNow = &open
+		if closesAt, ok := location.Hours.ClosesAt(now); ok {
+			store.ClosesAt = &closesAt
+		}
+	}
+	return store
+}
+
+// Maps a Kroger product to a product, with an offer for each of its items
+func ProductFromKroger(product models.Product) Product {
+	mapped := Product{
+		Id:             product.ProductId,
+		Upc:            product.Upc,
+		Brand:          product.Brand,
+		Description:    product.Description,
+		Categories:     product.Categories,
+		CountryOrigin:  product.CountryOrigin,
+		Temperature:    product.Temperature.Indicator,
+		Images:         []Image{},
+		Offers:         make([]Offer, 0, len(product.Items)),
+		ShelfLocations: make([]ShelfLocation, 0, len(product.AisleLocations)),
+	}
+	if mapped.Categories == nil {
+		mapped.Categories = []string{}
+	}
+
+	for _, image := range product.Images {
+		for _, size := range image.Sizes {
+			mapped.Images = append(mapped.Images, Image{
+				Perspective: image.Perspective,
+				Size:        size.Size,
+				Url:         size.Url,
+				Default:     image.Default,
+			})
+		}
+	}
+	for _, item := range product.Items {
+		mapped.Offers = append(mapped.Offers, OfferFromKroger(item))
+	}
+	for _, aisle := range models.ParseAisles(product.AisleLocations) {
+		mapped.ShelfLocations = append(mapped.ShelfLocations, ShelfLocation{
+			Aisle:      aisle.Number,
+			Department: aisle.Description,
+			Side:       aisle.Side,
+			Bay:        aisle.Bay,
+			Shelf:      aisle.Shelf,
+			Position:   aisle.Position,
+			Facings:    aisle.Facings,
+			SortKey:    aisle.SortKey,
+			Directions: aisle.Directions,
+		})
+	}
+	return mapped
+}
+
+// Maps a Kroger item to an offer. Kroger gives a price of zero when the product was not looked up for a
+// store, which is mapped to no price.
+func OfferFromKroger(item models.Item) Offer {
+	offer := Offer{
+		ItemId:     item.ItemId,
+		Size:       item.Size,
+		SoldBy:     SoldByUnit,
+		StockLevel: stockLevelFromKroger(item.Inventory.StockLevel),
+		Fulfillment: Fulfillment{
+			Curbside:   item.Fulfillment.Curbside,
+			Delivery:   item.Fulfillment.Delivery,
+			InStore:    item.Fulfillment.InStore,
+			ShipToHome: item.Fulfillment.ShipToHome,
+		},
+	}
+	if strings.EqualFold(item.SoldBy, "WEIGHT") {
+		offer.SoldBy = SoldByWeight
+	}
+	offer.InStock = offer.StockLevel == StockHigh || offer.StockLevel == StockLow
+
+	if item.Price.Regular > 0 {
+		price, regular := item.Price.Effective(), item.Price.Regular
+		offer.Price, offer.RegularPrice = &price, &regular
+		if unitPrice, unit, err := unitsize.UnitPrice(price, item.Size, item.SoldBy); err == nil {
+			offer.UnitPrice = &UnitPrice{Price: unitPrice, Unit: unit.Name}
+		}
+	}
+	return offer
+}
+
+// Maps a Kroger chain to a chain
+func ChainFromKroger(chain models.Chain) Chain {
+	mapped := Chain{Name: chain.Name, DivisionNumbers: chain.DivisionNumbers}
+	if mapped.DivisionNumbers == nil {
+		mapped.DivisionNumbers = []string{}
+	}
+	return mapped
+}
+
+// Maps a Kroger department to a department
+func DepartmentFromKroger(department models.Department) Department {
+	return Department{Id: department.DepartmentID, Name: department.Name}
+}
+
+// Maps a Kroger locations response to a stores response
+func StoresFromKroger(locations *models.LocationsResponse, now time.Time) StoresResponse {
+	response := StoresResponse{
+		Data: make([]Store, 0, len(locations.Data)),
+		Meta: metaFromKroger(locations.Meta.Warnings, locations.Meta.Freshness),
+	}
+	pagination := Pagination(locations.Meta.Pagination)
+	response.Meta.Pagination = &pagination
+	for _, location := range locations.Data {
+		response.Data = append(response.Data, StoreFromKroger(location, now))
+	}
+	return response
+}
+
+// Maps a Kroger location response to a store response
+func StoreResponseFromKroger(location *models.LocationResponse, now time.Time) StoreResponse {
+	return StoreResponse{
+		Data: StoreFromKroger(location.Data, now),
+		Meta: metaFromKroger(location.Meta.Warnings, location.Meta.Freshness),
+	}
+}
+
+// Maps a Kroger products response to a products response
+func ProductsFromKroger(products *models.ProductsResponse) ProductsResponse {
+	response := ProductsResponse{
+		Data: make([]Product, 0, len(products.Data)),
+		Meta: metaFromKroger(products.Meta.Warnings, products.Meta.Freshness),
+	}
+	pagination := Pagination(products.Meta.Pagination)
+	response.Meta.Pagination = &pagination
+	for _, product := range products.Data {
+		response.Data = append(response.Data, ProductFromKroger(product))
+	}
+	return response
+}
+
+// Maps a Kroger product response to a product response
+func ProductResponseFromKroger(product *models.ProductResponse) ProductResponse {
+	return ProductResponse{
+		Data: ProductFromKroger(product.Data),
+		Meta: metaFromKroger(product.Meta.Warnings, product.Meta.Freshness),
+	}
+}
+
+// Maps a Kroger chains response to a chains response
+func ChainsFromKroger(chains *models.ChainsResponse) ChainsResponse {
+	response := ChainsResponse{
+		Data: make([]Chain, 0, len(chains.Data)),
+		Meta: metaFromKroger(chains.Meta.Warnings, chains.Meta.Freshness),
+	}
+	for _, chain := range chains.Data {
+		response.Data = append(response.Data, ChainFromKroger(chain))
+	}
+	return response
+}
+
+// Maps a Kroger chain response to a chain response
+func ChainResponseFromKroger(chain *models.ChainResponse) ChainResponse {
+	return ChainResponse{
+		Data: ChainFromKroger(chain.Data),
+		Meta: metaFromKroger(chain.Meta.Warnings, chain.Meta.Freshness),
+	}
+}
+
+// Maps a Kroger departments response to a departments response
+func DepartmentsFromKroger(departments *models.DepartmentsResponse) DepartmentsResponse {
+	response := DepartmentsResponse{
+		Data: make([]Department, 0, len(departments.Data)),
+		Meta: metaFromKroger(departments.Meta.Warnings, departments.Meta.Freshness),
+	}
+	for _, department := range departments.Data {
+		response.Data = append(response.Data, DepartmentFromKroger(department))
+	}
+	return response
+}
+
+// Maps a Kroger department response to a department response
+func DepartmentResponseFromKroger(department *models.DepartmentResponse) DepartmentResponse {
+	return DepartmentResponse{
+		Data: DepartmentFromKroger(department.Data),
+		Meta: metaFromKroger(department.Meta.Warnings, department.Meta.Freshness),
+	}
+}
+
+// Maps a latitude or longitude through its shortest decimal form, so 45.4347 is written as 45.4347 rather
+// than the 45.43470001220703 a plain conversion from float32 gives
+func coordinateFromKroger(value float32) float64 {
+	coordinate, err := strconv.ParseFloat(strconv.FormatFloat(float64(value), 'f', -1, 32), 64)
+	if err != nil {
+		return float64(value)
+	}
+	return coordinate
+}
+
+// Maps the warnings and freshness of a Kroger response
+func metaFromKroger(warnings []string, freshness models.Freshness) Meta {
+	return Meta{Warnings: warnings, Stale: freshness.Stale, Age: freshness.Age}
+}
+
+// Maps a store's weekly hours, leaving out the days it does not open
+func hoursFromKroger(hours models.StoreHours) Hours {
+	mapped := Hours{Timezone: hours.Timezone, Open24: hours.Open24, Days: []DayHours{}}
+	for _, weekday := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		day := hours.Day(weekday)
+		if !day.Open24 && (day.Open == "" || day.Close == "") {
+			continue
+		}
+		mapped.Days = append(mapped.Days, DayHours{
+			Day:    strings.ToLower(weekday.String()),
+			Open:   day.Open,
+			Close:  day.Close,
+			Open24: day.Open24,
+		})
+	}
+	return mapped
+}
+
+// Maps one of Kroger's stock levels
+func stockLevelFromKroger(level string) string {
+	switch level {
+	case krogerStockHigh:
+		return StockHigh
+	case krogerStockLow:
+		return StockLow
+	case krogerStockOut:
+		return StockOut
+	}
+	return StockUnknown
+}
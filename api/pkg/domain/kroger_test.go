@@ -0,0 +1,219 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+	"github.com/jondysinger/grocery-data/api/pkg/money"
+)
+
+func TestStoreFromKroger(t *testing.T) {
+	var location models.Location
+	location.LocationId = "70100393"
+	location.Chain = "FRED"
+	location.Name = "Fred Meyer - Tigard"
+	location.Address.AddressLine1 = "11565 SW Pacific Hwy"
+	location.Address.ZipCode = "97223"
+	location.Geolocation.Latitude = 45.4347
+	location.Geolocation.Longitude = -122.7636
+	location.Departments = []models.Department{{DepartmentID: "09", Name: "Pharmacy"}}
+	location.Hours = models.StoreHours{
+		Timezone: "America/Los_Angeles",
+		Monday:   models.DayHours{Open: "06:00", Close: "23:00"},
+		Sunday:   models.DayHours{Open24: true},
+	}
+
+	// Monday 30 May 2022 at 12:00 in Portland
+	now := time.Date(2022, 5, 30, 19, 0, 0, 0, time.UTC)
+	store := StoreFromKroger(location, now)
+
+	if store.Id != "70100393" || store.Name != "Fred Meyer - Tigard" || store.Address.Line1 != "11565 SW Pacific Hwy" || store.Address.ZipCode != "97223" {
+		t.Errorf("unexpected store %+v", store)
+	}
+	if store.Latitude != 45.4347 || store.Longitude != -122.7636 {
+		t.Errorf("expected coordinates 45.4347, -122.7636 but got %v, %v", store.Latitude, store.Longitude)
+	}
+	if !reflect.DeepEqual(store.Departments, []Department{{Id: "09", Name: "Pharmacy"}}) {
+		t.Errorf("unexpected departments %+v", store.Departments)
+	}
+
+	expectedDays := []DayHours{{Day: "monday", Open: "06:00", Close: "23:00"}, {Day: "sunday", Open24: true}}
+	if store.Hours.Timezone != "America/Los_Angeles" || !reflect.DeepEqual(store.Hours.Days, expectedDays) {
+		t.Errorf("unexpected hours %+v", store.Hours)
+	}
+	if store.OpenNow == nil || !*store.OpenNow {
+		t.Errorf("expected the store to be open")
+	} else if store.ClosesAt == nil || !store.ClosesAt.Equal(time.Date(2022, 5, 31, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the store to close at 23:00 but got %v", store.ClosesAt)
+	}
+
+	// The name is written with our own key rather than Kroger's "Name"
+	out, err := json.Marshal(store)
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if !strings.Contains(string(out), `"name":"Fred Meyer - Tigard"`) {
+		t.Errorf("expected a name key but got %s", out)
+	}
+
+	// Coordinates are written as Kroger gives them, without the noise of widening a float32
+	if !strings.Contains(string(out), `"latitude":45.4347,"longitude":-122.7636`) {
+		t.Errorf("expected the coordinates as given but got %s", out)
+	}
+
+	// Unknown hours give no open status
+	location.Hours = models.StoreHours{}
+	if store := StoreFromKroger(location, now); store.OpenNow != nil || store.ClosesAt != nil || len(store.Hours.Days) != 0 {
+		t.Errorf("expected no open status but got %+v", store)
+	}
+}
+
+func TestOfferFromKroger(t *testing.T) {
+	testCases := []struct {
+		name       string
+		item       func(item *models.Item)
+		price      string
+		regular    string
+		unitPrice  *UnitPrice
+		stockLevel string
+		inStock    bool
+	}{
+		{
+			"regular price",
+			func(item *models.Item) {
+				item.Price.Regular = money.MustParse("3.84")
+				item.Inventory.StockLevel = "HIGH"
+			},
+			"3.84", "3.84", &UnitPrice{Price: money.MustParse("0.03"), Unit: "fl oz"}, StockHigh, true,
+		},
+		{
+			"on promotion",
+			func(item *models.Item) {
+				item.Price = models.Price{Regular: money.MustParse("3.84"), Promo: money.MustParse("2.56")}
+				item.Inventory.StockLevel = "LOW"
+			},
+			"2.56", "3.84", &UnitPrice{Price: money.MustParse("0.02"), Unit: "fl oz"}, StockLow, true,
+		},
+		{
+			"out of stock",
+			func(item *models.Item) {
+				item.Price.Regular = money.MustParse("3.84")
+				item.Inventory.StockLevel = "TEMPORARILY_OUT_OF_STOCK"
+			},
+			"3.84", "3.84", &UnitPrice{Price: money.MustParse("0.03"), Unit: "fl oz"}, StockOut, false,
+		},
+		{
+			"not looked up for a store",
+			func(item *models.Item) {},
+			"", "", nil, StockUnknown, false,
+		},
+		{
+			"unknown size",
+			func(item *models.Item) {
+				item.Price.Regular = money.MustParse("1.99")
+				item.Size = "large"
+			},
+			"1.99", "1.99", nil, StockUnknown, false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			item := models.Item{ItemId: "0001111041700", Size: "1 gal", SoldBy: "UNIT"}
+			item.Fulfillment.Curbside = true
+			tc.item(&item)
+
+			offer := OfferFromKroger(item)
+			if offer.ItemId != item.ItemId || offer.Size != item.Size || offer.SoldBy != SoldByUnit || !offer.Fulfillment.Curbside {
+				t.Errorf("unexpected offer %+v", offer)
+			}
+			if tc.price == "" {
+				if offer.Price != nil || offer.RegularPrice != nil {
+					t.Errorf("expected no prices but got %v and %v", offer.Price, offer.RegularPrice)
+				}
+			} else if offer.Price == nil || *offer.Price != money.MustParse(tc.price) || offer.RegularPrice == nil || *offer.RegularPrice != money.MustParse(tc.regular) {
+				t.Errorf("expected price %s of %s but got %v of %v", tc.price, tc.regular, offer.Price, offer.RegularPrice)
+			}
+			if !reflect.DeepEqual(offer.UnitPrice, tc.unitPrice) {
+				t.Errorf("expected unit price %+v but got %+v", tc.unitPrice, offer.UnitPrice)
+			}
+			if offer.StockLevel != tc.stockLevel || offer.InStock != tc.inStock {
+				t.Errorf("expected stock level '%s' but got '%s'", tc.stockLevel, offer.StockLevel)
+			}
+		})
+	}
+}
+
+func TestProductFromKroger(t *testing.T) {
+	product := models.Product{
+		ProductId:   "0001111041700",
+		Upc:         "0001111041700",
+		Brand:       "Kroger",
+		Description: "Kroger 2% Reduced Fat Milk",
+		AisleLocations: []models.AisleLocation{
+			{Number: "24", BayNumber: "4", Side: "L", ShelfNumber: "3", Description: "DAIRY"},
+			{Number: "3", BayNumber: "1", Side: "R", ShelfNumber: "1", Description: "ENDCAP"},
+		},
+		Items: []models.Item{{ItemId: "0001111041700", Size: "1 lb", SoldBy: "WEIGHT"}},
+	}
+	product.Temperature.Indicator = "Refrigerated"
+	image := models.Image{Perspective: "front", Default: true}
+	image.Sizes = append(image.Sizes, struct {
+		Id   string `json:"id"`
+		Size string `json:"size"`
+		Url  string `json:"url"`
+	}{Id: "1", Size: "thumbnail", Url: "https://example.com/thumbnail.jpg"})
+	product.Images = []models.Image{image}
+
+	mapped := ProductFromKroger(product)
+	if mapped.Id != product.ProductId || mapped.Brand != "Kroger" || mapped.Temperature != "Refrigerated" || mapped.Categories == nil {
+		t.Errorf("unexpected product %+v", mapped)
+	}
+	expectedImages := []Image{{Perspective: "front", Size: "thumbnail", Url: "https://example.com/thumbnail.jpg", Default: true}}
+	if !reflect.DeepEqual(mapped.Images, expectedImages) {
+		t.Errorf("expected images %+v but got %+v", expectedImages, mapped.Images)
+	}
+	if len(mapped.Offers) != 1 || mapped.Offers[0].SoldBy != SoldByWeight {
+		t.Errorf("unexpected offers %+v", mapped.Offers)
+	}
+
+	// Shelf locations are in walking order
+	if len(mapped.ShelfLocations) != 2 {
+		t.Fatalf("expected 2 shelf locations but got %d", len(mapped.ShelfLocations))
+	}
+	first := mapped.ShelfLocations[0]
+	if first.Aisle != 3 || first.Side != "right" || first.Directions != "Aisle 3, right side, bay 1, 1st shelf from bottom" {
+		t.Errorf("unexpected first shelf location %+v", first)
+	}
+	if mapped.ShelfLocations[1].Department != "DAIRY" {
+		t.Errorf("unexpected second shelf location %+v", mapped.ShelfLocations[1])
+	}
+
+	// Comparisons take the first offer and the directions to the first shelf location
+	compared := mapped.Compared()
+	if compared.Id != product.ProductId || compared.Size != "1 lb" || compared.Aisle != first.Directions || compared.Price != nil {
+		t.Errorf("unexpected compared product %+v", compared)
+	}
+}
+
+func TestProductsFromKroger(t *testing.T) {
+	var products models.ProductsResponse
+	products.Data = []models.Product{{ProductId: "0001111041700"}, {ProductId: "0004138703023"}}
+	products.Meta.Pagination.Total = 40
+	products.Meta.Pagination.Start = 10
+	products.Meta.Pagination.Limit = 2
+	products.Meta.Warnings = []string{"partial results"}
+	products.Meta.Freshness = models.Freshness{Stale: true, Age: 90}
+
+	mapped := ProductsFromKroger(&products)
+	if len(mapped.Data) != 2 || mapped.Data[1].Id != "0004138703023" {
+		t.Errorf("unexpected products %+v", mapped.Data)
+	}
+	expected := Meta{Pagination: &Pagination{Total: 40, Start: 10, Limit: 2}, Warnings: []string{"partial results"}, Stale: true, Age: 90}
+	if !reflect.DeepEqual(mapped.Meta, expected) {
+		t.Errorf("expected meta %+v but got %+v", expected, mapped.Meta)
+	}
+}
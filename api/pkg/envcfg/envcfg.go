@@ -1,8 +1,12 @@
 package envcfg
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type EnvCfg struct {
@@ -12,6 +16,34 @@ type EnvCfg struct {
 	KrogerApiClientSecret string
 	KrogerApiChain        string
 	GroceryDataAppUrl     string
+
+	// Calls per second allowed to each Kroger endpoint. Zero disables throttling.
+	KrogerApiRateLimit float64
+	// Calls that can be made back to back before throttling starts
+	KrogerApiRateBurst int
+	// Longest a call is queued by the throttle before it is rejected
+	KrogerApiMaxWait time.Duration
+	// Calls allowed per day keyed by endpoint, e.g. "products"
+	KrogerApiDailyLimits map[string]int
+	// File the daily call counts are saved to. The counts are kept in memory only when empty.
+	KrogerApiUsageFile string
+	// Bearer token required by the admin routes, which are disabled when empty
+	AdminToken string
+
+	// Where cached responses are kept, either "memory" or "disk"
+	CacheBackend string
+	// Directory the disk cache is kept in
+	CacheDir string
+	// How long locations, chains and departments are cached. Zero disables caching them.
+	CacheLocationTtl time.Duration
+	// How long product searches and lookups are cached. Zero disables caching them.
+	CacheProductTtl time.Duration
+	// Largest total size of the cached responses in bytes
+	CacheMaxBytes int64
+	// How long after expiring cached responses are served when the Kroger API fails
+	CacheStaleIfError time.Duration
+	// How long after expiring cached responses are served while being refreshed in the background
+	CacheStaleWhileRevalidate time.Duration
 }
 
 func Get() *EnvCfg {
@@ -23,6 +55,14 @@ func Get() *EnvCfg {
 		return v
 	}
 
+	getenv := func(k string, fallback string) string {
+		v := os.Getenv(k)
+		if v == "" {
+			return fallback
+		}
+		return v
+	}
+
 	var cfg EnvCfg
 
 	// Get environment variables
@@ -33,5 +73,64 @@ func Get() *EnvCfg {
 	cfg.KrogerApiChain = mustGetenv("KROGER_API_CHAIN")
 	cfg.GroceryDataAppUrl = mustGetenv("GROCERY_DATA_APP_URL")
 
+	// Get optional environment variables. The default limits match Kroger's for public API clients.
+	var err error
+	if cfg.KrogerApiRateLimit, err = strconv.ParseFloat(getenv("KROGER_API_RATE_LIMIT", "10"), 64); err != nil {
+		log.Fatalf("warning: KROGER_API_RATE_LIMIT environment variable is invalid, %v", err)
+	}
+	if cfg.KrogerApiRateBurst, err = strconv.Atoi(getenv("KROGER_API_RATE_BURST", "20")); err != nil {
+		log.Fatalf("warning: KROGER_API_RATE_BURST environment variable is invalid, %v", err)
+	}
+	if cfg.KrogerApiMaxWait, err = time.ParseDuration(getenv("KROGER_API_MAX_WAIT", "30s")); err != nil {
+		log.Fatalf("warning: KROGER_API_MAX_WAIT environment variable is invalid, %v", err)
+	}
+	if cfg.KrogerApiDailyLimits, err = parseLimits(getenv("KROGER_API_DAILY_LIMITS", "locations=1600,products=10000,chains=1600,departments=1600")); err != nil {
+		log.Fatalf("warning: KROGER_API_DAILY_LIMITS environment variable is invalid, %v", err)
+	}
+	cfg.KrogerApiUsageFile = os.Getenv("KROGER_API_USAGE_FILE")
+	cfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+
+	cfg.CacheBackend = getenv("CACHE_BACKEND", "memory")
+	if cfg.CacheBackend != "memory" && cfg.CacheBackend != "disk" {
+		log.Fatalf("warning: CACHE_BACKEND environment variable is invalid, must be memory or disk")
+	}
+	cfg.CacheDir = getenv("CACHE_DIR", "cache")
+	if cfg.CacheLocationTtl, err = time.ParseDuration(getenv("CACHE_LOCATION_TTL", "24h")); err != nil {
+		log.Fatalf("warning: CACHE_LOCATION_TTL environment variable is invalid, %v", err)
+	}
+	if cfg.CacheProductTtl, err = time.ParseDuration(getenv("CACHE_PRODUCT_TTL", "5m")); err != nil {
+		log.Fatalf("warning: CACHE_PRODUCT_TTL environment variable is invalid, %v", err)
+	}
+	if cfg.CacheMaxBytes, err = strconv.ParseInt(getenv("CACHE_MAX_BYTES", "67108864"), 10, 64); err != nil {
+		log.Fatalf("warning: CACHE_MAX_BYTES environment variable is invalid, %v", err)
+	}
+	if cfg.CacheStaleIfError, err = time.ParseDuration(getenv("CACHE_STALE_IF_ERROR", "24h")); err != nil {
+		log.Fatalf("warning: CACHE_STALE_IF_ERROR environment variable is invalid, %v", err)
+	}
+	if cfg.CacheStaleWhileRevalidate, err = time.ParseDuration(getenv("CACHE_STALE_WHILE_REVALIDATE", "1m")); err != nil {
+		log.Fatalf("warning: CACHE_STALE_WHILE_REVALIDATE environment variable is invalid, %v", err)
+	}
+
 	return &cfg
 }
+
+// Parses a comma separated list of name=number pairs, e.g. "products=10000,locations=1600"
+func parseLimits(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		name, number, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a name=number pair", pair)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(number))
+		if err != nil {
+			return nil, err
+		}
+		limits[strings.TrimSpace(name)] = limit
+	}
+	return limits, nil
+}
@@ -0,0 +1,68 @@
+package kclient
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jondysinger/grocery-data/api/pkg/cassette"
+)
+
+// Base URL the cassette is replayed against. Requests are matched by path, so it only needs the same /v1
+// prefix as the API the cassette was recorded from.
+const cassetteBaseUrl = "https://api.kroger.com/v1"
+
+// Runs the client against recorded Kroger responses. Set KCLIENT_RECORD=1 to record the cassette again
+// against the configured API, which is the real one when KCLIENT_LIVE_TESTS=1 is also set.
+func TestCassetteReplay(t *testing.T) {
+	path := filepath.Join("testdata", "kroger.cassette.json")
+	mode := cassette.ModeReplay
+	baseUrl := cassetteBaseUrl
+	if os.Getenv("KCLIENT_RECORD") != "" {
+		mode = cassette.ModeRecord
+		baseUrl = cfg.KrogerApiBaseUrl
+	}
+
+	rec, err := cassette.New(path, mode, nil, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret)
+	if err != nil {
+		t.Fatalf("error during cassette setup, %v", err)
+	}
+
+	client, err := New(baseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain, WithHttpClient(&http.Client{Transport: rec}))
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	ctx := context.Background()
+	locations, err := client.GetLocations(ctx, LocationQuery{ZipCode: "97224", Limit: 5})
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if len(locations.Data) < 1 {
+		t.Fatalf("expected at least one location but got %d", len(locations.Data))
+	}
+
+	locationId := locations.Data[0].LocationId
+	products, err := client.GetProducts(ctx, ProductQuery{Term: "milk", LocationId: locationId, Limit: 5})
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if len(products.Data) < 1 {
+		t.Fatalf("expected at least one product but got %d", len(products.Data))
+	}
+
+	product, err := client.GetProduct(ctx, products.Data[0].ProductId, locationId)
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if product.Data.ProductId != products.Data[0].ProductId {
+		t.Fatalf("expected product %s but got %s", products.Data[0].ProductId, product.Data.ProductId)
+	}
+
+	if _, err := client.GetLocation(ctx, "99999999"); err == nil {
+		t.Fatal("expected err but got none")
+	}
+
+	if err := rec.Save(); err != nil {
+		t.Fatalf("failed to save cassette, %v", err)
+	}
+}
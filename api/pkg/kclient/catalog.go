@@ -0,0 +1,60 @@
+package kclient
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Gets all of the chains owned by Kroger
+func (client *KClient) GetChains(ctx context.Context) (*models.ChainsResponse, error) {
+	// API Reference: https://developer.kroger.com/reference#operation/getChains
+	var chainsResp models.ChainsResponse
+	if err := client.do(ctx, apiRequest{method: "GET", path: "/chains"}, &chainsResp); err != nil {
+		return nil, err
+	}
+
+	return &chainsResp, nil
+}
+
+// Gets the details of a single chain by its name, e.g. "FRED"
+func (client *KClient) GetChain(ctx context.Context, name string) (*models.ChainResponse, error) {
+	if name == "" {
+		return nil, newValidationError("parameter 'name' is required")
+	}
+
+	// API Reference: https://developer.kroger.com/reference#operation/getChain
+	var chainResp models.ChainResponse
+	if err := client.do(ctx, apiRequest{method: "GET", path: "/chains/" + url.PathEscape(name)}, &chainResp); err != nil {
+		return nil, err
+	}
+
+	return &chainResp, nil
+}
+
+// Gets all of the departments that can be found in Kroger stores, such as a pharmacy or fuel center
+func (client *KClient) GetDepartments(ctx context.Context) (*models.DepartmentsResponse, error) {
+	// API Reference: https://developer.kroger.com/reference#operation/getDepartments
+	var deptsResp models.DepartmentsResponse
+	if err := client.do(ctx, apiRequest{method: "GET", path: "/departments"}, &deptsResp); err != nil {
+		return nil, err
+	}
+
+	return &deptsResp, nil
+}
+
+// Gets the details of a single department by its ID
+func (client *KClient) GetDepartment(ctx context.Context, departmentId string) (*models.DepartmentResponse, error) {
+	if departmentId == "" {
+		return nil, newValidationError("parameter 'departmentId' is required")
+	}
+
+	// API Reference: https://developer.kroger.com/reference#operation/getDepartment
+	var deptResp models.DepartmentResponse
+	if err := client.do(ctx, apiRequest{method: "GET", path: "/departments/" + url.PathEscape(departmentId)}, &deptResp); err != nil {
+		return nil, err
+	}
+
+	return &deptResp, nil
+}
@@ -0,0 +1,64 @@
+package kclient
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetChains(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	chains, err := client.GetChains(context.Background())
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if len(chains.Data) < 1 {
+		t.Fatalf("expected at least one chain but got %d", len(chains.Data))
+	}
+}
+
+func TestGetChain(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	chain, err := client.GetChain(context.Background(), cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if chain.Data.Name != cfg.KrogerApiChain {
+		t.Fatalf("expected chain %s but got %s", cfg.KrogerApiChain, chain.Data.Name)
+	}
+
+	if _, err := client.GetChain(context.Background(), ""); err == nil {
+		t.Error("expected err but got none")
+	}
+}
+
+func TestGetDepartments(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	departments, err := client.GetDepartments(context.Background())
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if len(departments.Data) < 1 {
+		t.Fatalf("expected at least one department but got %d", len(departments.Data))
+	}
+
+	departmentId := departments.Data[0].DepartmentID
+	department, err := client.GetDepartment(context.Background(), departmentId)
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if department.Data.DepartmentID != departmentId {
+		t.Fatalf("expected department %s but got %s", departmentId, department.Data.DepartmentID)
+	}
+
+	if _, err := client.GetDepartment(context.Background(), ""); err == nil {
+		t.Error("expected err but got none")
+	}
+}
@@ -0,0 +1,183 @@
+package kclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Reports how many calls were shared, implemented by CoalescingClient
+type CoalesceReporter interface {
+	CoalesceStats() models.CoalesceStats
+}
+
+// GroceryClient that lets identical calls made at the same time share one call to the wrapped client and
+// its result. The shared call keeps running as long as any caller is waiting for it, so one caller giving up
+// does not fail the others. Every caller gets its own copy of the response.
+type CoalescingClient struct {
+	client GroceryClient
+
+	mu      sync.Mutex
+	flights map[string]*flight
+	calls   int64
+	saved   int64
+}
+
+var _ GroceryClient = (*CoalescingClient)(nil)
+var _ CoalesceReporter = (*CoalescingClient)(nil)
+
+// An in-flight call and the callers waiting for it
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	joined  bool
+	result  interface{}
+	err     error
+	// The result serialized for the callers that joined the call, so none of them share the original
+	data []byte
+}
+
+// Creates a CoalescingClient that wraps client
+func NewCoalescingClient(client GroceryClient) *CoalescingClient {
+	return &CoalescingClient{
+		client:  client,
+		flights: make(map[string]*flight),
+	}
+}
+
+// Gets the number of calls made to the wrapped client and the number of calls saved by sharing them
+func (coalescer *CoalescingClient) CoalesceStats() models.CoalesceStats {
+	return models.CoalesceStats{
+		Calls: atomic.LoadInt64(&coalescer.calls),
+		Saved: atomic.LoadInt64(&coalescer.saved),
+	}
+}
+
+// Makes the call identified by key, or waits for the same call already in flight and gets a copy of its
+// result. The call runs with its own context, which is cancelled once every caller has stopped waiting.
+func coalesce[T any](coalescer *CoalescingClient, ctx context.Context, key string, call func(ctx context.Context) (*T, error)) (*T, error) {
+	coalescer.mu.Lock()
+	f, joined := coalescer.flights[key]
+	if joined {
+		f.waiters++
+		f.joined = true
+		atomic.AddInt64(&coalescer.saved, 1)
+	} else {
+		callCtx, cancel := context.WithCancel(context.Background())
+		f = &flight{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		coalescer.flights[key] = f
+		atomic.AddInt64(&coalescer.calls, 1)
+		go coalescer.run(key, f, callCtx, func(ctx context.Context) (interface{}, error) {
+			return call(ctx)
+		})
+	}
+	coalescer.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		coalescer.leave(key, f)
+		return nil, ctx.Err()
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	} else if !joined {
+		return f.result.(*T), nil
+	}
+
+	var resp T
+	if err := json.Unmarshal(f.data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to copy shared response: %v", err)
+	}
+	return &resp, nil
+}
+
+// Runs a call and hands its result to the callers waiting for it
+func (coalescer *CoalescingClient) run(key string, f *flight, ctx context.Context, call func(ctx context.Context) (interface{}, error)) {
+	defer f.cancel()
+	result, err := call(ctx)
+
+	// Stop new callers from joining before deciding whether the result needs copying
+	coalescer.mu.Lock()
+	if coalescer.flights[key] == f {
+		delete(coalescer.flights, key)
+	}
+	joined := f.joined
+	coalescer.mu.Unlock()
+
+	f.result, f.err = result, err
+	if err == nil && joined {
+		if f.data, err = json.Marshal(result); err != nil {
+			f.err = fmt.Errorf("failed to copy shared response: %v", err)
+		}
+	}
+	close(f.done)
+}
+
+// Stops waiting for a call, cancelling it when no one else is waiting
+func (coalescer *CoalescingClient) leave(key string, f *flight) {
+	coalescer.mu.Lock()
+	defer coalescer.mu.Unlock()
+
+	f.waiters--
+	if f.waiters == 0 {
+		f.cancel()
+		if coalescer.flights[key] == f {
+			delete(coalescer.flights, key)
+		}
+	}
+}
+
+func (coalescer *CoalescingClient) GetLocations(ctx context.Context, query LocationQuery) (*models.LocationsResponse, error) {
+	return coalesce(coalescer, ctx, RequestKey("GetLocations", query), func(ctx context.Context) (*models.LocationsResponse, error) {
+		return coalescer.client.GetLocations(ctx, query)
+	})
+}
+
+func (coalescer *CoalescingClient) GetLocation(ctx context.Context, locationId string) (*models.LocationResponse, error) {
+	return coalesce(coalescer, ctx, RequestKey("GetLocation", locationId), func(ctx context.Context) (*models.LocationResponse, error) {
+		return coalescer.client.GetLocation(ctx, locationId)
+	})
+}
+
+func (coalescer *CoalescingClient) GetProducts(ctx context.Context, query ProductQuery) (*models.ProductsResponse, error) {
+	return coalesce(coalescer, ctx, RequestKey("GetProducts", query), func(ctx context.Context) (*models.ProductsResponse, error) {
+		return coalescer.client.GetProducts(ctx, query)
+	})
+}
+
+func (coalescer *CoalescingClient) GetProduct(ctx context.Context, productId string, locationId string) (*models.ProductResponse, error) {
+	return coalesce(coalescer, ctx, RequestKey("GetProduct", productId, locationId), func(ctx context.Context) (*models.ProductResponse, error) {
+		return coalescer.client.GetProduct(ctx, productId, locationId)
+	})
+}
+
+func (coalescer *CoalescingClient) GetChains(ctx context.Context) (*models.ChainsResponse, error) {
+	return coalesce(coalescer, ctx, RequestKey("GetChains"), func(ctx context.Context) (*models.ChainsResponse, error) {
+		return coalescer.client.GetChains(ctx)
+	})
+}
+
+func (coalescer *CoalescingClient) GetChain(ctx context.Context, name string) (*models.ChainResponse, error) {
+	return coalesce(coalescer, ctx, RequestKey("GetChain", name), func(ctx context.Context) (*models.ChainResponse, error) {
+		return coalescer.client.GetChain(ctx, name)
+	})
+}
+
+func (coalescer *CoalescingClient) GetDepartments(ctx context.Context) (*models.DepartmentsResponse, error) {
+	return coalesce(coalescer, ctx, RequestKey("GetDepartments"), func(ctx context.Context) (*models.DepartmentsResponse, error) {
+		return coalescer.client.GetDepartments(ctx)
+	})
+}
+
+func (coalescer *CoalescingClient) GetDepartment(ctx context.Context, departmentId string) (*models.DepartmentResponse, error) {
+	return coalesce(coalescer, ctx, RequestKey("GetDepartment", departmentId), func(ctx context.Context) (*models.DepartmentResponse, error) {
+		return coalescer.client.GetDepartment(ctx, departmentId)
+	})
+}
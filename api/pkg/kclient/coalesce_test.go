@@ -0,0 +1,189 @@
+package kclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// GroceryClient whose product searches block until released, counting the calls made
+type blockingClient struct {
+	GroceryClient
+	release chan struct{}
+	calls   int64
+	err     error
+}
+
+func (blocking *blockingClient) GetProducts(ctx context.Context, query ProductQuery) (*models.ProductsResponse, error) {
+	atomic.AddInt64(&blocking.calls, 1)
+	select {
+	case <-blocking.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if blocking.err != nil {
+		return nil, blocking.err
+	}
+
+	var resp models.ProductsResponse
+	resp.Data = []models.Product{{ProductId: "0001111041700", Description: query.Term}}
+	return &resp, nil
+}
+
+// Waits until the coalescer has counted the given number of saved calls
+func waitForSaved(t *testing.T, coalescer *CoalescingClient, saved int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for coalescer.CoalesceStats().Saved < saved {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d saved calls but got %d", saved, coalescer.CoalesceStats().Saved)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Makes the same product search from several goroutines, returning once they have all joined one call
+func searchConcurrently(t *testing.T, coalescer *CoalescingClient, callers int) ([]*models.ProductsResponse, []error, func()) {
+	resps := make([]*models.ProductsResponse, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resps[i], errs[i] = coalescer.GetProducts(context.Background(), ProductQuery{Term: "milk"})
+		}(i)
+	}
+	waitForSaved(t, coalescer, int64(callers-1))
+	return resps, errs, wg.Wait
+}
+
+func TestCoalesceIdenticalCalls(t *testing.T) {
+	blocking := &blockingClient{release: make(chan struct{})}
+	coalescer := NewCoalescingClient(blocking)
+
+	resps, errs, wait := searchConcurrently(t, coalescer, 5)
+	close(blocking.release)
+	wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		} else if len(resps[i].Data) != 1 || resps[i].Data[0].Description != "milk" {
+			t.Fatalf("unexpected response %+v", resps[i].Data)
+		}
+	}
+	if calls := atomic.LoadInt64(&blocking.calls); calls != 1 {
+		t.Errorf("expected 1 upstream call but got %d", calls)
+	}
+	if stats := coalescer.CoalesceStats(); stats.Calls != 1 || stats.Saved != 4 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// Each caller gets its own copy of the response
+	resps[0].Data[0].Description = "changed"
+	for _, resp := range resps[1:] {
+		if resp.Data[0].Description != "milk" {
+			t.Fatalf("expected callers not to share responses")
+		}
+	}
+}
+
+func TestCoalesceSharesErrors(t *testing.T) {
+	blocking := &blockingClient{release: make(chan struct{}), err: ErrNotFound}
+	coalescer := NewCoalescingClient(blocking)
+
+	_, errs, wait := searchConcurrently(t, coalescer, 3)
+	close(blocking.release)
+	wait()
+
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound but got %v", err)
+		}
+	}
+	if calls := atomic.LoadInt64(&blocking.calls); calls != 1 {
+		t.Errorf("expected 1 upstream call but got %d", calls)
+	}
+}
+
+func TestCoalesceDifferentCalls(t *testing.T) {
+	blocking := &blockingClient{release: make(chan struct{})}
+	close(blocking.release)
+	coalescer := NewCoalescingClient(blocking)
+
+	for _, term := range []string{"milk", "eggs", "milk"} {
+		if _, err := coalescer.GetProducts(context.Background(), ProductQuery{Term: term}); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+	}
+
+	// Calls that do not overlap are never shared
+	if stats := coalescer.CoalesceStats(); stats.Calls != 3 || stats.Saved != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCoalesceCallerCancelled(t *testing.T) {
+	blocking := &blockingClient{release: make(chan struct{})}
+	coalescer := NewCoalescingClient(blocking)
+
+	// The caller that started the call gives up, but the one that joined it still gets the response
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := coalescer.GetProducts(ctx, ProductQuery{Term: "milk"})
+		first <- err
+	}()
+	for atomic.LoadInt64(&blocking.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := coalescer.GetProducts(context.Background(), ProductQuery{Term: "milk"})
+		second <- err
+	}()
+	waitForSaved(t, coalescer, 1)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+	close(blocking.release)
+	if err := <-second; err != nil {
+		t.Errorf("expected success but got error, %v", err)
+	}
+}
+
+func TestCoalesceAllCallersCancelled(t *testing.T) {
+	blocking := &blockingClient{release: make(chan struct{})}
+	coalescer := NewCoalescingClient(blocking)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := coalescer.GetProducts(ctx, ProductQuery{Term: "milk"})
+		done <- err
+	}()
+	for atomic.LoadInt64(&blocking.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled but got %v", err)
+	}
+
+	// With no one waiting the call was abandoned, so the next caller makes a new one
+	close(blocking.release)
+	if _, err := coalescer.GetProducts(context.Background(), ProductQuery{Term: "milk"}); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	if stats := coalescer.CoalesceStats(); stats.Calls != 2 || stats.Saved != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,134 @@
+package kclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Sentinel errors for classifying failures with errors.Is
+var (
+	// The request was rejected before or by the Kroger API because of invalid parameters
+	ErrValidation = errors.New("invalid request")
+	// The Kroger API rejected the client credentials or token
+	ErrUnauthorized = errors.New("unauthorized")
+	// The requested resource does not exist
+	ErrNotFound = errors.New("not found")
+	// The Kroger API is throttling requests
+	ErrRateLimited = errors.New("rate limited")
+	// The Kroger API failed with a server error
+	ErrUpstream = errors.New("upstream server error")
+	// The client's own rate limit or daily quota rejected the request before it was sent
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// The Kroger API sent a response body larger than the client accepts
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+// Error returned when the Kroger API responds with an unsuccessful status code
+type APIError struct {
+	StatusCode int
+	Status     string
+	// Error code reported by Kroger, e.g. "PRODUCT-2011-400" or "invalid_client"
+	Code      string
+	Reason    string
+	Timestamp time.Time
+}
+
+func (e *APIError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("request failed with status '%s'", e.Status)
+	}
+	return fmt.Sprintf("request failed with status '%s', Error description: %s", e.Status, e.Reason)
+}
+
+// Matches the sentinel error that corresponds to the status code
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrValidation:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUpstream:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// Error returned when a parameter fails validation before any request is made
+type ValidationError struct {
+	Message string
+}
+
+// Creates a ValidationError with a formatted message
+func newValidationError(format string, a ...interface{}) *ValidationError {
+	return &ValidationError{Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Matches ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Error returned when the client's rate limiter rejects a request without sending it
+type LimitError struct {
+	Endpoint string
+	// The daily quota that was used up, when Daily is set
+	Limit int
+	// Whether the endpoint's daily quota is used up, as opposed to the request having to wait too long to be
+	// sent without exceeding the rate limit
+	Daily bool
+	// When a request to the endpoint is allowed again
+	RetryAt time.Time
+}
+
+func (e *LimitError) Error() string {
+	if e.Daily {
+		return fmt.Sprintf("daily quota of %d requests to the %s endpoint is used up until %s", e.Limit, e.Endpoint, e.RetryAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("rate limit for the %s endpoint exceeded, retry at %s", e.Endpoint, e.RetryAt.Format(time.RFC3339))
+}
+
+// Matches ErrQuotaExceeded
+func (e *LimitError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Builds an APIError from an unsuccessful response. Kroger uses one body shape for API errors and another
+// for authorization errors, so both are tried.
+func getResponseError(statusCode int, status string, body []byte) error {
+	apiErr := &APIError{StatusCode: statusCode, Status: status}
+
+	var errRes models.ApiErrorResponse
+	if err := json.Unmarshal(body, &errRes); err == nil && errRes.Errors.Reason != "" {
+		apiErr.Code = errRes.Errors.Code
+		apiErr.Reason = errRes.Errors.Reason
+		if errRes.Errors.TimeStamp > 0 {
+			apiErr.Timestamp = time.UnixMilli(int64(errRes.Errors.TimeStamp))
+		}
+		return apiErr
+	}
+
+	var authRes models.AuthErrorResponse
+	if err := json.Unmarshal(body, &authRes); err == nil && (authRes.Error != "" || authRes.ErrorDescription != "") {
+		apiErr.Code = authRes.Error
+		apiErr.Reason = authRes.ErrorDescription
+		return apiErr
+	}
+
+	if statusCode == http.StatusNotFound {
+		apiErr.Reason = "URL endpoint not found"
+	}
+	return apiErr
+}
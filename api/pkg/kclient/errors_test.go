@@ -0,0 +1,67 @@
+package kclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetResponseError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+		code       string
+		reason     string
+		sentinel   error
+	}{
+		{"api error", 400, `{"errors":{"timestamp":1654041600000,"code":"PRODUCT-2011-400","reason":"Field 'locationId' must have a length of 8 characters"}}`, "PRODUCT-2011-400", "Field 'locationId' must have a length of 8 characters", ErrValidation},
+		{"auth error", 401, `{"error":"invalid_client","error_description":"Client authentication failed"}`, "invalid_client", "Client authentication failed", ErrUnauthorized},
+		{"forbidden", 403, `{"error":"insufficient_scope","error_description":"Scope not granted"}`, "insufficient_scope", "Scope not granted", ErrUnauthorized},
+		{"not found without body", 404, ``, "", "URL endpoint not found", ErrNotFound},
+		{"rate limited", 429, `{"errors":{"code":"RATE-429","reason":"Too many requests"}}`, "RATE-429", "Too many requests", ErrRateLimited},
+		{"server error with html body", 502, `<html>Bad Gateway</html>`, "", "", ErrUpstream},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := getResponseError(tc.statusCode, "status", []byte(tc.body))
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected an APIError but got %T", err)
+			}
+			if apiErr.StatusCode != tc.statusCode || apiErr.Code != tc.code || apiErr.Reason != tc.reason {
+				t.Errorf("expected (%d, %s, %s) but got (%d, %s, %s)", tc.statusCode, tc.code, tc.reason, apiErr.StatusCode, apiErr.Code, apiErr.Reason)
+			}
+			if !errors.Is(err, tc.sentinel) {
+				t.Errorf("expected error to match %v", tc.sentinel)
+			}
+		})
+	}
+}
+
+func TestGetResponseErrorTimestamp(t *testing.T) {
+	err := getResponseError(500, "500 Internal Server Error", []byte(`{"errors":{"timestamp":1654041600000,"code":"X","reason":"boom"}}`))
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError but got %T", err)
+	}
+	if expected := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC); !apiErr.Timestamp.Equal(expected) {
+		t.Errorf("expected timestamp %v but got %v", expected, apiErr.Timestamp)
+	}
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrNotFound) {
+		t.Error("expected a server error to only match ErrUpstream")
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := newValidationError("parameter '%s' is required", "zipCode")
+	if !errors.Is(err, ErrValidation) {
+		t.Error("expected error to match ErrValidation")
+	}
+	if err.Error() != "parameter 'zipCode' is required" {
+		t.Errorf("unexpected message %s", err.Error())
+	}
+}
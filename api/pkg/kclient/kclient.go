@@ -1,21 +1,33 @@
 package kclient
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
-	"io/ioutil"
-	"math"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/jondysinger/grocery-data/api/pkg/models"
 )
 
-const maxAttempts = 6
+// Grocery data operations used by the API. KClient implements it against the Kroger API, and it can be
+// replaced with a fake in tests.
+type GroceryClient interface {
+	GetLocations(ctx context.Context, query LocationQuery) (*models.LocationsResponse, error)
+	GetLocation(ctx context.Context, locationId string) (*models.LocationResponse, error)
+	GetProducts(ctx context.Context, query ProductQuery) (*models.ProductsResponse, error)
+	GetProduct(ctx context.Context, productId string, locationId string) (*models.ProductResponse, error)
+	GetChains(ctx context.Context) (*models.ChainsResponse, error)
+	GetChain(ctx context.Context, name string) (*models.ChainResponse, error)
+	GetDepartments(ctx context.Context) (*models.DepartmentsResponse, error)
+	GetDepartment(ctx context.Context, departmentId string) (*models.DepartmentResponse, error)
+}
+
+var _ GroceryClient = (*KClient)(nil)
+
+// Largest response body accepted from the Kroger API when the client is not given a limit. A page of 50
+// products with store details is a few hundred kilobytes, so this leaves plenty of room.
+const DefaultMaxResponseBytes = 16 << 20
 
 // Struct for interaction with Kroger API
 type KClient struct {
@@ -23,12 +35,68 @@ type KClient struct {
 	id        string
 	secret    string
 	chain     string
-	token     string
+	auth      *tokenSource
 	netClient *http.Client
+	retry     RetryPolicy
+	clock     Clock
+	random    func(n int64) int64
+	limits    map[string]RateLimit
+	quota     QuotaStore
+	limiter   *limiter
+	// Largest response body accepted, zero or less for no limit
+	maxResponseBytes int64
+}
+
+// Optional setting applied to a KClient when it is created
+type Option func(*KClient)
+
+// Sets the policy used to retry failed requests
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(client *KClient) {
+		client.retry = policy
+	}
+}
+
+// Sets the clock used for token expiry and retry delays
+func WithClock(clock Clock) Option {
+	return func(client *KClient) {
+		client.clock = clock
+	}
+}
+
+// Sets the HTTP client used to call the Kroger API
+func WithHttpClient(netClient *http.Client) Option {
+	return func(client *KClient) {
+		client.netClient = netClient
+	}
+}
+
+// Sets the throttling and daily quota for each endpoint, replacing DefaultRateLimits. Endpoints that are not
+// in the map are not limited.
+func WithRateLimits(limits map[string]RateLimit) Option {
+	return func(client *KClient) {
+		client.limits = limits
+	}
+}
+
+// Sets the store the daily call counts are persisted to. Without one the counts start over when the client
+// is created. Counts are saved every few seconds and when the client is closed.
+func WithQuotaStore(store QuotaStore) Option {
+	return func(client *KClient) {
+		client.quota = store
+	}
+}
+
+// Sets the largest response body accepted from the Kroger API, replacing DefaultMaxResponseBytes. Larger
+// responses fail with ErrResponseTooLarge. Zero or less removes the limit.
+func WithMaxResponseBytes(maxBytes int64) Option {
+	return func(client *KClient) {
+		client.maxResponseBytes = maxBytes
+	}
 }
 
 // Creates a new KClient
-func New(baseUrl string, id string, secret string, chain string) (*KClient, error) {
+func New(baseUrl string, id string, secret string, chain string, opts ...Option) (*KClient, error) {
 	if baseUrl == "" {
 		return nil, errors.New("parameter 'baseUrl' is required")
 	} else if id == "" {
@@ -43,229 +111,133 @@ func New(baseUrl string, id string, secret string, chain string) (*KClient, erro
 		Timeout: time.Second * 180,
 	}
 
-	client := KClient{
+	client := &KClient{
 		baseUrl:   baseUrl,
 		id:        id,
 		secret:    secret,
 		chain:     chain,
 		netClient: netClient,
+		retry:     DefaultRetryPolicy(),
+		clock:     systemClock{},
+		random:    defaultRandom,
+		limits:    DefaultRateLimits(),
+
+		maxResponseBytes: DefaultMaxResponseBytes,
+	}
+	for _, opt := range opts {
+		opt(client)
 	}
-	return &client, nil
-}
 
-// Gets the error description from a Kroger API error response (status codes 400 or 500)
-func getApiErrorDesc(body []byte) (string, error) {
-	var errRes models.ApiErrorResponse
-	if err := json.Unmarshal(body, &errRes); err != nil {
-		return "", fmt.Errorf("failed to deserialize JSON response body: %v", err)
+	limiter, err := newLimiter(client.limits, client.quota, client.clock)
+	if err != nil {
+		return nil, err
 	}
-	return errRes.Errors.Reason, nil
+	client.limiter = limiter
+
+	client.auth = newTokenSource(client.fetchAuthToken)
+	client.auth.now = client.clock.Now
+	return client, nil
 }
 
-// Gets the error description from a Kroger Authorization error response (status code 401)
-func getAuthErrorDesc(body []byte) (string, error) {
-	var errRes models.AuthErrorResponse
-	if err := json.Unmarshal(body, &errRes); err != nil {
-		return "", fmt.Errorf("failed to deserialize JSON response body: %v", err)
-	}
-	return errRes.ErrorDescription, nil
+// Gets the number of calls made to each limited endpoint today and how many are left
+func (client *KClient) Usage() []models.EndpointUsage {
+	return client.limiter.report()
 }
 
-// Gets the error description from the response body of a Kroger API call based on the status code
-func getResponseError(statusCode int, status string, body []byte) error {
-	switch statusCode {
-	case 404:
-		return errors.New("URL endpoint not found")
-	case 400, 500:
-		desc, err := getApiErrorDesc(body)
-		if err != nil {
-			desc = err.Error()
-		}
-		return fmt.Errorf("request failed with status '%s', Error description: %s", status, desc)
-	case 401:
-		desc, err := getAuthErrorDesc(body)
-		if err != nil {
-			desc = err.Error()
-		}
-		return fmt.Errorf("request failed with status '%s', Error description: %s", status, desc)
-	}
-	return fmt.Errorf("unknown error with status '%s'", status)
+// Saves the daily call counts to the QuotaStore and stops saving them in the background. The client can
+// still be used, but counts made afterwards are not saved.
+func (client *KClient) Close() error {
+	client.limiter.close()
+	return nil
 }
 
-// Retrieves a client authentication OAuth2 token
-func (client *KClient) GetAuthToken() error {
-	reqUrl := fmt.Sprintf("%s/connect/oauth2/token", client.baseUrl)
-	payload := strings.NewReader("grant_type=client_credentials&scope=product.compact")
-
-	var attempts = 0
-	for {
-		// Exponential backoff for retry on failed attempt
-		if attempts > maxAttempts {
-			return fmt.Errorf("exceeded maximum retries")
-		} else if attempts > 0 {
-			var delay = 5 * math.Pow(2, float64(attempts))
-			time.Sleep(time.Second * time.Duration(delay))
-		}
-
-		// API Reference: https://developer.kroger.com/reference#operation/accessToken
-		req, err := http.NewRequest("POST", reqUrl, payload)
-		if err != nil {
-			return fmt.Errorf("request failed: %v", err)
-		}
-
-		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", client.id, client.secret)))))
-		res, err := client.netClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("request failed: %v", err)
-		}
-
-		defer res.Body.Close()
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response body: %v", err)
-		} else if res.StatusCode >= 500 {
-			attempts++
-			continue // Retry on internal server errors
-		} else if res.StatusCode != 200 {
-			return getResponseError(res.StatusCode, res.Status, body)
-		}
-
-		var authRes models.AuthorizationResponse
-		if err := json.Unmarshal(body, &authRes); err != nil {
-			return fmt.Errorf("failed to deserialize JSON response body: %v", err)
-		}
-
-		client.token = authRes.AccessToken
-		return nil
+// Retrieves a new client authentication OAuth2 token. Calling this is optional since the client obtains
+// and renews its token as needed, but it is useful for verifying the credentials up front.
+func (client *KClient) GetAuthToken(ctx context.Context) error {
+	_, err := client.auth.Refresh(ctx)
+	return err
+}
+
+// Requests a client authentication OAuth2 token from the Kroger API
+func (client *KClient) fetchAuthToken(ctx context.Context) (string, time.Duration, error) {
+	// API Reference: https://developer.kroger.com/reference#operation/accessToken
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("scope", "product.compact")
+
+	var authRes models.AuthorizationResponse
+	if err := client.do(ctx, apiRequest{method: "POST", path: "/connect/oauth2/token", form: form}, &authRes); err != nil {
+		return "", 0, err
 	}
+
+	return authRes.AccessToken, time.Second * time.Duration(authRes.ExpiresIn), nil
 }
 
-// Gets Kroger locations by zip code
-func (client *KClient) GetLocations(zipCode string, filterLimit int) (*models.LocationsResponse, error) {
-	if client.token == "" {
-		return nil, errors.New("client has no OAuth2 token, call GetAuthToken first")
-	} else if zipCode == "" {
-		return nil, errors.New("parameter 'zipCode' is required")
-	} else if digits := countDigits(zipCode); digits < 5 || digits != len(zipCode) {
-		return nil, fmt.Errorf("parameter 'zipCode' value '%s' is invalid. Must be a number with 5 digits", zipCode)
-	} else if filterLimit < 0 || filterLimit > 200 {
-		return nil, fmt.Errorf("parameter 'filterLimit' value %d is invalid. Valid values are 0 to 200", filterLimit)
+// Gets Kroger locations near a zip code or coordinates, or by ID
+func (client *KClient) GetLocations(ctx context.Context, query LocationQuery) (*models.LocationsResponse, error) {
+	values, err := query.values(client.chain)
+	if err != nil {
+		return nil, err
 	}
 
-	reqUrl := fmt.Sprintf("%s/locations?filter.chain=%s&filter.zipCode.near=%s", client.baseUrl, client.chain, url.QueryEscape(zipCode))
+	// API Reference: https://developer.kroger.com/reference#operation/SearchLocations
+	var locsResp models.LocationsResponse
+	if err := client.do(ctx, apiRequest{method: "GET", path: "/locations", query: values}, &locsResp); err != nil {
+		return nil, err
+	}
 
-	if filterLimit > 0 {
-		reqUrl = fmt.Sprintf("%s&filter.limit=%d", reqUrl, filterLimit)
+	return &locsResp, nil
+}
+
+// Gets the details of a single Kroger location by its ID, including store hours and departments
+func (client *KClient) GetLocation(ctx context.Context, locationId string) (*models.LocationResponse, error) {
+	if locationId == "" {
+		return nil, newValidationError("parameter 'locationId' is required")
 	}
 
-	var attempts = 0
-	for {
-		// Exponential backoff for retry on failed attempt
-		if attempts > maxAttempts {
-			return nil, fmt.Errorf("exceeded maximum retries")
-		} else if attempts > 0 {
-			var delay = 5 * math.Pow(2, float64(attempts))
-			time.Sleep(time.Second * time.Duration(delay))
-		}
-
-		// API Reference: https://developer.kroger.com/reference#operation/SearchLocations
-		req, err := http.NewRequest("GET", reqUrl, nil)
-		if err != nil {
-			return nil, fmt.Errorf("request failed: %v", err)
-		}
-
-		req.Header.Add("Accept", "application/json")
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", client.token))
-		res, err := client.netClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("request failed: %v", err)
-		}
-
-		defer res.Body.Close()
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response body: %v", err)
-		} else if res.StatusCode >= 500 {
-			attempts++
-			continue // Retry on internal server errors
-		} else if res.StatusCode != 200 {
-			return nil, getResponseError(res.StatusCode, res.Status, body)
-		}
-
-		var locsResp models.LocationsResponse
-		if err := json.Unmarshal(body, &locsResp); err != nil {
-			return nil, fmt.Errorf("failed to deserialize JSON response body: %v", err)
-		}
-
-		return &locsResp, nil
+	// API Reference: https://developer.kroger.com/reference#operation/getLocation
+	var locResp models.LocationResponse
+	if err := client.do(ctx, apiRequest{method: "GET", path: "/locations/" + url.PathEscape(locationId)}, &locResp); err != nil {
+		return nil, err
 	}
+
+	return &locResp, nil
 }
 
-// Gets Kroger products based on a given search term. A locationId is optional and if given the product information
-// will contain stock levels and pricing.
-func (client *KClient) GetProducts(filterTerm string, locationId string, filterOffset int, filterLimit int) (*models.ProductsResponse, error) {
-	if client.token == "" {
-		return nil, errors.New("client has no OAuth2 token, call GetAuthToken first")
-	} else if filterTerm == "" {
-		return nil, errors.New("parameter 'filterTerm' is required")
-	} else if filterOffset < 0 || filterOffset > 1000 {
-		return nil, fmt.Errorf("parameter 'filterOffset' value %d is invalid. Valid values are 0 to 1000", filterOffset)
-	} else if filterLimit < 0 || filterLimit > 50 {
-		return nil, fmt.Errorf("parameter 'filterLimit' value %d is invalid. Valid values are 0 to 50", filterLimit)
+// Gets Kroger products matching a search term, brand or list of IDs. A locationId is optional and if given the
+// product information will contain stock levels and pricing.
+func (client *KClient) GetProducts(ctx context.Context, query ProductQuery) (*models.ProductsResponse, error) {
+	values, err := query.values()
+	if err != nil {
+		return nil, err
 	}
 
-	reqUrl := fmt.Sprintf("%s/products?filter.term=%s", client.baseUrl, url.QueryEscape(filterTerm))
-	if locationId != "" {
-		reqUrl = fmt.Sprintf("%s&filter.locationId=%s", reqUrl, url.QueryEscape(locationId))
+	// API Reference: https://developer.kroger.com/reference#operation/productGet
+	var prodResp models.ProductsResponse
+	if err := client.do(ctx, apiRequest{method: "GET", path: "/products", query: values}, &prodResp); err != nil {
+		return nil, err
 	}
 
-	if filterOffset > 0 {
-		reqUrl = fmt.Sprintf("%s&filter.start=%d", reqUrl, filterOffset)
+	return &prodResp, nil
+}
+
+// Gets the details of a single Kroger product by its ID. A locationId is optional and if given the product
+// information will contain aisle locations, stock levels and pricing for that store.
+func (client *KClient) GetProduct(ctx context.Context, productId string, locationId string) (*models.ProductResponse, error) {
+	if productId == "" {
+		return nil, newValidationError("parameter 'productId' is required")
 	}
 
-	if filterLimit > 0 {
-		reqUrl = fmt.Sprintf("%s&filter.limit=%d", reqUrl, filterLimit)
+	query := url.Values{}
+	if locationId != "" {
+		query.Set("filter.locationId", locationId)
 	}
 
-	var attempts = 0
-	for {
-		// Exponential backoff for retry on failed attempt
-		if attempts > maxAttempts {
-			return nil, fmt.Errorf("exceeded maximum retries")
-		} else if attempts > 0 {
-			var delay = 5 * math.Pow(2, float64(attempts))
-			time.Sleep(time.Second * time.Duration(delay))
-		}
-		// API Reference: https://developer.kroger.com/reference#operation/productGet
-		req, err := http.NewRequest("GET", reqUrl, nil)
-		if err != nil {
-			return nil, fmt.Errorf("request failed: %v", err)
-		}
-
-		req.Header.Add("Accept", "application/json")
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", client.token))
-		res, err := client.netClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("request failed: %v", err)
-		}
-
-		defer res.Body.Close()
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response body: %v", err)
-		} else if res.StatusCode >= 500 {
-			attempts++
-			continue // Retry on internal server errors
-		} else if res.StatusCode != 200 {
-			return nil, getResponseError(res.StatusCode, res.Status, body)
-		}
-
-		var prodResp models.ProductsResponse
-		if err := json.Unmarshal(body, &prodResp); err != nil {
-			return nil, fmt.Errorf("failed to deserialize JSON response body: %v", err)
-		}
-
-		return &prodResp, nil
+	// API Reference: https://developer.kroger.com/reference#operation/productGet
+	var prodResp models.ProductResponse
+	if err := client.do(ctx, apiRequest{method: "GET", path: "/products/" + url.PathEscape(productId), query: query}, &prodResp); err != nil {
+		return nil, err
 	}
+
+	return &prodResp, nil
 }
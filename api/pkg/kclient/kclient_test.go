@@ -1,20 +1,44 @@
 package kclient
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/jondysinger/grocery-data/api/pkg/envcfg"
+	"github.com/jondysinger/grocery-data/api/pkg/krogersim"
 )
 
 var cfg *envcfg.EnvCfg
+var simApi *krogersim.Server
+var sim *httptest.Server
 
 func oneTimeSetup() {
-	// Get environment variables
-	cfg = envcfg.Get()
+	// Run against the real Kroger API when asked to, using the environment variables for credentials
+	if os.Getenv("KCLIENT_LIVE_TESTS") != "" {
+		cfg = envcfg.Get()
+		return
+	}
+
+	// Otherwise run against the simulated Kroger API, served under the same /v1 prefix as the real one
+	simApi = krogersim.New(krogersim.DefaultFixtures(), "test-id", "test-secret")
+	sim = httptest.NewServer(http.StripPrefix("/v1", simApi))
+	cfg = &envcfg.EnvCfg{
+		KrogerApiBaseUrl:      sim.URL + "/v1",
+		KrogerApiClientId:     "test-id",
+		KrogerApiClientSecret: "test-secret",
+		KrogerApiChain:        "FRED",
+	}
 }
 
 func oneTimeTeardown() {
+	if sim != nil {
+		sim.Close()
+	}
 }
 
 func TestMain(m *testing.M) {
@@ -59,7 +83,7 @@ func TestGetAuthToken(t *testing.T) {
 		t.Fatalf("error during client setup, %v", err)
 	}
 
-	if err := client.GetAuthToken(); err != nil {
+	if err := client.GetAuthToken(context.Background()); err != nil {
 		t.Fatalf("expected success but got error, %v", err)
 	}
 }
@@ -84,7 +108,7 @@ func TestGetAuthTokenInvalidParam(t *testing.T) {
 				t.Fatalf("failure in setup code, %v", err)
 			}
 
-			if err := client.GetAuthToken(); err == nil {
+			if err := client.GetAuthToken(context.Background()); err == nil {
 				t.Error("expected err but got none")
 			}
 		})
@@ -96,11 +120,11 @@ func TestGetLocations(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error during client setup, %v", err)
 	}
-	if err := client.GetAuthToken(); err != nil {
+	if err := client.GetAuthToken(context.Background()); err != nil {
 		t.Fatalf("error during auth setup, %v", err)
 	}
 
-	locations, err := client.GetLocations("97224", 10)
+	locations, err := client.GetLocations(context.Background(), LocationQuery{ZipCode: "97224", Limit: 10})
 	if err != nil {
 		t.Fatalf("expected success but got error, %v", err)
 	} else if len(locations.Data) < 1 {
@@ -113,7 +137,7 @@ func TestGetLocationsInvalidParam(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error during client setup, %v", err)
 	}
-	if err := client.GetAuthToken(); err != nil {
+	if err := client.GetAuthToken(context.Background()); err != nil {
 		t.Fatalf("error during auth setup, %v", err)
 	}
 	testCases := []struct {
@@ -129,7 +153,43 @@ func TestGetLocationsInvalidParam(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if _, err := client.GetLocations(tc.zip, tc.limit); err == nil {
+			if _, err := client.GetLocations(context.Background(), LocationQuery{ZipCode: tc.zip, Limit: tc.limit}); err == nil {
+				t.Error("expected err but got none")
+			}
+		})
+	}
+}
+
+func TestGetLocation(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	location, err := client.GetLocation(context.Background(), "70100393")
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if location.Data.LocationId != "70100393" {
+		t.Fatalf("expected location 70100393 but got %s", location.Data.LocationId)
+	}
+}
+
+func TestGetLocationInvalidParam(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+	testCases := []struct {
+		name       string
+		locationId string
+	}{
+		{"locationId missing", ""},
+		{"locationId unknown", "99999999"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := client.GetLocation(context.Background(), tc.locationId); err == nil {
 				t.Error("expected err but got none")
 			}
 		})
@@ -141,11 +201,11 @@ func TestGetProducts(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error during client setup, %v", err)
 	}
-	if err := client.GetAuthToken(); err != nil {
+	if err := client.GetAuthToken(context.Background()); err != nil {
 		t.Fatalf("error during auth setup, %v", err)
 	}
 
-	products, err := client.GetProducts("milk", "70100393", 0, 1)
+	products, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", LocationId: "70100393", Limit: 1})
 	if err != nil {
 		t.Fatalf("expected success but got error, %v", err)
 	} else if len(products.Data) != 1 {
@@ -158,7 +218,7 @@ func TestGetProductsInvalidParam(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error during client setup, %v", err)
 	}
-	if err := client.GetAuthToken(); err != nil {
+	if err := client.GetAuthToken(context.Background()); err != nil {
 		t.Fatalf("error during auth setup, %v", err)
 	}
 	testCases := []struct {
@@ -175,9 +235,98 @@ func TestGetProductsInvalidParam(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if _, err := client.GetProducts(tc.filter, tc.loc, tc.offset, tc.limit); err == nil {
+			if _, err := client.GetProducts(context.Background(), ProductQuery{Term: tc.filter, LocationId: tc.loc, Start: tc.offset, Limit: tc.limit}); err == nil {
+				t.Error("expected err but got none")
+			}
+		})
+	}
+}
+
+func TestGetProduct(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	products, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", LocationId: "70100393", Limit: 1})
+	if err != nil || len(products.Data) != 1 {
+		t.Fatalf("error during product search setup, %v", err)
+	}
+
+	productId := products.Data[0].ProductId
+	product, err := client.GetProduct(context.Background(), productId, "70100393")
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if product.Data.ProductId != productId {
+		t.Fatalf("expected product %s but got %s", productId, product.Data.ProductId)
+	}
+}
+
+func TestGetProductInvalidParam(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+	testCases := []struct {
+		name      string
+		productId string
+		loc       string
+	}{
+		{"productId missing", "", "70100393"},
+		{"locationId invalid", "0001111041700", "12345"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := client.GetProduct(context.Background(), tc.productId, tc.loc); err == nil {
 				t.Error("expected err but got none")
 			}
 		})
 	}
 }
+
+func TestGetProductsCancelledDuringBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/connect/oauth2/token" {
+			w.Write([]byte(`{"access_token":"abc","expires_in":1800,"token_type":"bearer"}`))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "id", "secret", "FRED")
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.GetProducts(ctx, ProductQuery{Term: "milk", Limit: 1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded but got %v", err)
+	} else if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the retry backoff to be interrupted but it took %v", elapsed)
+	}
+}
+
+func TestGetProductsAfterTokenRevoked(t *testing.T) {
+	if simApi == nil {
+		t.Skip("requires the simulated Kroger API")
+	}
+
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+	if err := client.GetAuthToken(context.Background()); err != nil {
+		t.Fatalf("error during auth setup, %v", err)
+	}
+
+	// The cached token is rejected, so the client should get a new one and retry
+	simApi.RevokeTokens()
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", Limit: 1}); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+// Package kclienttest provides an in-memory kclient.GroceryClient for testing code that depends on the
+// Kroger API without a network connection or credentials.
+package kclienttest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Names of the GroceryClient methods, used to inject errors and inspect calls
+const (
+	GetLocations   = "GetLocations"
+	GetLocation    = "GetLocation"
+	GetProducts    = "GetProducts"
+	GetProduct     = "GetProduct"
+	GetChains      = "GetChains"
+	GetChain       = "GetChain"
+	GetDepartments = "GetDepartments"
+	GetDepartment  = "GetDepartment"
+)
+
+// A recorded call to the fake
+type Call struct {
+	Method string
+	// The arguments after the context, e.g. a kclient.ProductQuery or an ID
+	Args []interface{}
+}
+
+// Programmable in-memory GroceryClient. The exported slices hold the canned data that searches and lookups
+// are answered from and may be set directly before use. A Fake is safe for concurrent use once populated.
+type Fake struct {
+	Locations   []models.Location
+	Products    []models.Product
+	Chains      []models.Chain
+	Departments []models.Department
+
+	mu    sync.Mutex
+	calls []Call
+	errs  map[string][]error
+}
+
+var _ kclient.GroceryClient = (*Fake)(nil)
+
+// Creates an empty Fake
+func New() *Fake {
+	return &Fake{}
+}
+
+// Makes every following call to the method fail with err until Reset is called
+func (fake *Fake) Fail(method string, err error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.errs == nil {
+		fake.errs = make(map[string][]error)
+	}
+	fake.errs[method] = []error{err}
+}
+
+// Makes the next calls to the method fail with the given errors in order, one error per call. A nil error
+// lets that call succeed. Once the errors are used up calls succeed again.
+func (fake *Fake) FailNext(method string, errs ...error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.errs == nil {
+		fake.errs = make(map[string][]error)
+	}
+	// A trailing nil keeps the last error from being treated as permanent
+	fake.errs[method] = append(append([]error{}, errs...), nil)
+}
+
+// Clears injected errors and recorded calls
+func (fake *Fake) Reset() {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.errs = nil
+	fake.calls = nil
+}
+
+// Gets the recorded calls to the method, or to every method if none is given
+func (fake *Fake) Calls(method ...string) []Call {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var calls []Call
+	for _, call := range fake.calls {
+		if len(method) == 0 || call.Method == method[0] {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Records a call and returns the error injected for it, if any
+func (fake *Fake) record(method string, args ...interface{}) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.calls = append(fake.calls, Call{Method: method, Args: args})
+
+	errs := fake.errs[method]
+	if len(errs) == 0 {
+		return nil
+	}
+	err := errs[0]
+	if len(errs) > 1 {
+		fake.errs[method] = errs[1:]
+	}
+	return err
+}
+
+// Creates the error the Kroger API returns for an unknown resource
+func notFound(kind string, id string) error {
+	return &kclient.APIError{
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Reason:     fmt.Sprintf("%s '%s' not found", kind, id),
+	}
+}
+
+func (fake *Fake) GetLocations(ctx context.Context, query kclient.LocationQuery) (*models.LocationsResponse, error) {
+	if err := fake.record(GetLocations, query); err != nil {
+		return nil, err
+	} else if err := query.Validate(); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var resp models.LocationsResponse
+	resp.Data = []models.Location{}
+	for _, location := range fake.Locations {
+		if !location.MatchesIds(query.LocationIds) {
+			continue
+		} else if query.Chain != "" && !strings.EqualFold(query.Chain, location.Chain) {
+			continue
+		} else if !location.HasDepartments(query.DepartmentIds) {
+			continue
+		}
+		resp.Data = append(resp.Data, location)
+	}
+
+	resp.Meta.Pagination.Total = len(resp.Data)
+	if query.Limit > 0 && len(resp.Data) > query.Limit {
+		resp.Data = resp.Data[:query.Limit]
+	}
+	resp.Meta.Pagination.Limit = len(resp.Data)
+	return &resp, nil
+}
+
+func (fake *Fake) GetLocation(ctx context.Context, locationId string) (*models.LocationResponse, error) {
+	if err := fake.record(GetLocation, locationId); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, location := range fake.Locations {
+		if location.LocationId == locationId {
+			var resp models.LocationResponse
+			resp.Data = location
+			return &resp, nil
+		}
+	}
+	return nil, notFound("location", locationId)
+}
+
+func (fake *Fake) GetProducts(ctx context.Context, query kclient.ProductQuery) (*models.ProductsResponse, error) {
+	if err := fake.record(GetProducts, query); err != nil {
+		return nil, err
+	} else if err := query.Validate(); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var matches []models.Product
+	for _, product := range fake.Products {
+		if !product.MatchesIds(query.ProductIds) {
+			continue
+		} else if query.Brand != "" && !strings.EqualFold(query.Brand, product.Brand) {
+			continue
+		} else if query.Term != "" && !strings.Contains(strings.ToLower(product.Description), strings.ToLower(query.Term)) {
+			continue
+		} else if query.Fulfillment != "" && !product.HasFulfillment(query.Fulfillment) {
+			continue
+		}
+		matches = append(matches, product)
+	}
+
+	// Page through the matches the same way the Kroger API does, with a default page size of 10
+	limit := query.Limit
+	if limit == 0 {
+		limit = 10
+	}
+	start := query.Start
+	if start > len(matches) {
+		start = len(matches)
+	}
+	end := start + limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	var resp models.ProductsResponse
+	resp.Data = append([]models.Product{}, matches[start:end]...)
+	resp.Meta.Pagination.Total = len(matches)
+	resp.Meta.Pagination.Start = query.Start
+	resp.Meta.Pagination.Limit = limit
+	return &resp, nil
+}
+
+func (fake *Fake) GetProduct(ctx context.Context, productId string, locationId string) (*models.ProductResponse, error) {
+	if err := fake.record(GetProduct, productId, locationId); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, product := range fake.Products {
+		if product.ProductId == productId {
+			var resp models.ProductResponse
+			resp.Data = product
+			return &resp, nil
+		}
+	}
+	return nil, notFound("product", productId)
+}
+
+func (fake *Fake) GetChains(ctx context.Context) (*models.ChainsResponse, error) {
+	if err := fake.record(GetChains); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var resp models.ChainsResponse
+	resp.Data = append([]models.Chain{}, fake.Chains...)
+	return &resp, nil
+}
+
+func (fake *Fake) GetChain(ctx context.Context, name string) (*models.ChainResponse, error) {
+	if err := fake.record(GetChain, name); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, chain := range fake.Chains {
+		if strings.EqualFold(chain.Name, name) {
+			var resp models.ChainResponse
+			resp.Data = chain
+			return &resp, nil
+		}
+	}
+	return nil, notFound("chain", name)
+}
+
+func (fake *Fake) GetDepartments(ctx context.Context) (*models.DepartmentsResponse, error) {
+	if err := fake.record(GetDepartments); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var resp models.DepartmentsResponse
+	resp.Data = append([]models.Department{}, fake.Departments...)
+	return &resp, nil
+}
+
+func (fake *Fake) GetDepartment(ctx context.Context, departmentId string) (*models.DepartmentResponse, error) {
+	if err := fake.record(GetDepartment, departmentId); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, department := range fake.Departments {
+		if department.DepartmentID == departmentId {
+			var resp models.DepartmentResponse
+			resp.Data = department
+			return &resp, nil
+		}
+	}
+	return nil, notFound("department", departmentId)
+}
@@ -0,0 +1,113 @@
+package kclienttest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jondysinger/grocery-data/api/pkg/kclient"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+func newTestFake() *Fake {
+	fake := New()
+	fake.Products = []models.Product{
+		{ProductId: "0001111041700", Brand: "Kroger", Description: "Kroger 2% Reduced Fat Milk"},
+		{ProductId: "0001111041600", Brand: "Kroger", Description: "Kroger Whole Milk"},
+		{ProductId: "0004138703023", Brand: "Darigold", Description: "Darigold Whole Milk"},
+		{ProductId: "0001111060932", Brand: "Kroger", Description: "Kroger Large Eggs"},
+	}
+
+	// Every product is sold in store, and only the 2% milk can also be delivered
+	for i := range fake.Products {
+		var item models.Item
+		item.Fulfillment.InStore = true
+		item.Fulfillment.Delivery = i == 0
+		fake.Products[i].Items = []models.Item{item}
+	}
+	return fake
+}
+
+func TestFakeGetProducts(t *testing.T) {
+	fake := newTestFake()
+	testCases := []struct {
+		name     string
+		query    kclient.ProductQuery
+		expected int
+		total    int
+	}{
+		{"term", kclient.ProductQuery{Term: "milk"}, 3, 3},
+		{"term and brand", kclient.ProductQuery{Term: "milk", Brand: "kroger"}, 2, 2},
+		{"product ids", kclient.ProductQuery{ProductIds: []string{"0001111060932"}}, 1, 1},
+		{"paged", kclient.ProductQuery{Term: "milk", Start: 2, Limit: 2}, 1, 3},
+		{"in store", kclient.ProductQuery{Term: "milk", LocationId: "70100393", Fulfillment: kclient.FulfillmentInStore}, 3, 3},
+		{"delivery", kclient.ProductQuery{Term: "milk", LocationId: "70100393", Fulfillment: kclient.FulfillmentDelivery}, 1, 1},
+		{"curbside", kclient.ProductQuery{Term: "milk", LocationId: "70100393", Fulfillment: kclient.FulfillmentCurbside}, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			products, err := fake.GetProducts(context.Background(), tc.query)
+			if err != nil {
+				t.Fatalf("expected success but got error, %v", err)
+			}
+			if len(products.Data) != tc.expected || products.Meta.Pagination.Total != tc.total {
+				t.Errorf("expected %d of %d products but got %d of %d", tc.expected, tc.total, len(products.Data), products.Meta.Pagination.Total)
+			}
+		})
+	}
+}
+
+func TestFakeValidatesQueries(t *testing.T) {
+	fake := newTestFake()
+	if _, err := fake.GetProducts(context.Background(), kclient.ProductQuery{Limit: 500}); !errors.Is(err, kclient.ErrValidation) {
+		t.Errorf("expected a validation error but got %v", err)
+	}
+}
+
+func TestFakeNotFound(t *testing.T) {
+	fake := newTestFake()
+	if _, err := fake.GetProduct(context.Background(), "missing", ""); !errors.Is(err, kclient.ErrNotFound) {
+		t.Errorf("expected a not found error but got %v", err)
+	}
+}
+
+func TestFakeInjectedErrors(t *testing.T) {
+	fake := newTestFake()
+	outage := errors.New("outage")
+
+	fake.FailNext(GetProduct, outage)
+	if _, err := fake.GetProduct(context.Background(), "0001111041700", ""); err != outage {
+		t.Fatalf("expected the injected error but got %v", err)
+	}
+	if _, err := fake.GetProduct(context.Background(), "0001111041700", ""); err != nil {
+		t.Fatalf("expected success after the injected error but got %v", err)
+	}
+
+	fake.Fail(GetChains, outage)
+	for i := 0; i < 3; i++ {
+		if _, err := fake.GetChains(context.Background()); err != outage {
+			t.Fatalf("expected the injected error on call %d but got %v", i, err)
+		}
+	}
+
+	fake.Reset()
+	if _, err := fake.GetChains(context.Background()); err != nil {
+		t.Errorf("expected success after reset but got %v", err)
+	}
+}
+
+func TestFakeRecordsCalls(t *testing.T) {
+	fake := newTestFake()
+	_, _ = fake.GetProduct(context.Background(), "0001111041700", "70100393")
+	_, _ = fake.GetProducts(context.Background(), kclient.ProductQuery{Term: "eggs"})
+
+	if calls := fake.Calls(); len(calls) != 2 {
+		t.Fatalf("expected 2 calls but got %d", len(calls))
+	}
+
+	calls := fake.Calls(GetProduct)
+	if len(calls) != 1 || calls[0].Args[0] != "0001111041700" || calls[0].Args[1] != "70100393" {
+		t.Errorf("unexpected GetProduct calls %v", calls)
+	}
+}
@@ -0,0 +1,361 @@
+package kclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Names of the Kroger API endpoints that calls are throttled and counted by
+const (
+	EndpointLocations   = "locations"
+	EndpointProducts    = "products"
+	EndpointChains      = "chains"
+	EndpointDepartments = "departments"
+)
+
+// Layout of the UTC day that daily call counts are kept for
+const usageDayLayout = "2006-01-02"
+
+// How often changed call counts are written to the QuotaStore. Counts made since the last write are lost if
+// the process crashes, so a restart can allow a few seconds' worth of extra calls.
+const usageSaveInterval = 5 * time.Second
+
+// Throttling and daily budget for calls to one Kroger API endpoint. Every request sent counts, including
+// retries, since Kroger counts them too.
+type RateLimit struct {
+	// Average number of calls allowed per second. Zero disables throttling.
+	PerSecond float64
+	// Number of calls that can be made back to back before throttling starts. Values below 1 are treated as 1.
+	Burst int
+	// Longest a call is queued waiting for the throttle before it is rejected. Zero queues calls until their
+	// context is done.
+	MaxWait time.Duration
+	// Number of calls allowed per UTC day. Calls beyond it are rejected until the next day. Zero is unlimited.
+	Daily int
+}
+
+// Gets the rate limits used by clients that are not given any. The daily limits are the ones Kroger sets for
+// public API clients.
+func DefaultRateLimits() map[string]RateLimit {
+	return map[string]RateLimit{
+		EndpointLocations:   {PerSecond: 10, Burst: 20, MaxWait: 30 * time.Second, Daily: 1600},
+		EndpointProducts:    {PerSecond: 10, Burst: 20, MaxWait: 30 * time.Second, Daily: 10000},
+		EndpointChains:      {PerSecond: 10, Burst: 20, MaxWait: 30 * time.Second, Daily: 1600},
+		EndpointDepartments: {PerSecond: 10, Burst: 20, MaxWait: 30 * time.Second, Daily: 1600},
+	}
+}
+
+// Number of calls made to each endpoint on one UTC day
+type Usage struct {
+	// The day formatted as 2006-01-02
+	Day    string         `json:"day"`
+	Counts map[string]int `json:"counts"`
+}
+
+// Persists daily call counts so that a restart does not reset the budget
+type QuotaStore interface {
+	// Loads the saved counts. A store with nothing saved returns an empty Usage.
+	Load() (Usage, error)
+	Save(usage Usage) error
+}
+
+// QuotaStore that keeps the counts in a JSON file
+type FileQuotaStore struct {
+	Path string
+}
+
+// Creates a QuotaStore backed by the file at path, which is created when the counts are first saved
+func NewFileQuotaStore(path string) *FileQuotaStore {
+	return &FileQuotaStore{Path: path}
+}
+
+func (store *FileQuotaStore) Load() (Usage, error) {
+	var usage Usage
+	data, err := ioutil.ReadFile(store.Path)
+	if os.IsNotExist(err) {
+		return usage, nil
+	} else if err != nil {
+		return usage, fmt.Errorf("failed to read usage file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return usage, fmt.Errorf("failed to deserialize usage file '%s': %v", store.Path, err)
+	}
+	return usage, nil
+}
+
+func (store *FileQuotaStore) Save(usage Usage) error {
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so a crash never leaves a partially written file behind
+	tmp, err := ioutil.TempFile(filepath.Dir(store.Path), filepath.Base(store.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write usage file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write usage file: %v", err)
+	} else if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write usage file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), store.Path); err != nil {
+		return fmt.Errorf("failed to write usage file: %v", err)
+	}
+	return nil
+}
+
+// Reports how much of the daily budget has been used, implemented by KClient
+type UsageReporter interface {
+	Usage() []models.EndpointUsage
+}
+
+var _ UsageReporter = (*KClient)(nil)
+
+// Token bucket for one endpoint
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Throttles calls per endpoint with token buckets and enforces the daily budgets
+type limiter struct {
+	limits map[string]RateLimit
+	store  QuotaStore
+	clock  Clock
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	usage   Usage
+	// Whether the counts changed since they were last saved
+	dirty bool
+
+	// Held while saving so snapshots are written in the order they were taken
+	saveMu sync.Mutex
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Creates a limiter and loads the counts saved in the store, if any. The counts in memory are the ones
+// enforced, and are saved to the store in the background every usageSaveInterval and when the limiter is
+// closed, so calls never wait on the store.
+func newLimiter(limits map[string]RateLimit, store QuotaStore, clock Clock) (*limiter, error) {
+	lim := &limiter{
+		limits:  limits,
+		store:   store,
+		clock:   clock,
+		buckets: make(map[string]*bucket),
+		done:    make(chan struct{}),
+	}
+
+	if store != nil {
+		usage, err := store.Load()
+		if err != nil {
+			return nil, err
+		}
+		lim.usage = usage
+	}
+	lim.rollover(clock.Now())
+
+	if store != nil {
+		go lim.saveEvery(usageSaveInterval)
+	}
+	return lim, nil
+}
+
+// Gets the endpoint a request path belongs to, e.g. "products" for "/products/0001111041700"
+func endpointOf(path string) string {
+	return strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+}
+
+// Resets the counts when the UTC day has changed. Must be called with lim.mu held.
+func (lim *limiter) rollover(now time.Time) {
+	day := now.UTC().Format(usageDayLayout)
+	if lim.usage.Day != day {
+		lim.usage = Usage{Day: day, Counts: make(map[string]int)}
+	} else if lim.usage.Counts == nil {
+		lim.usage.Counts = make(map[string]int)
+	}
+}
+
+// Gets the time the daily counts are next reset
+func nextReset(now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
+
+// Waits until a call to the endpoint is allowed and counts it. Calls are rejected with a *LimitError once
+// the endpoint's daily budget is used up, or when the throttle would queue them for longer than MaxWait.
+func (lim *limiter) acquire(ctx context.Context, endpoint string) error {
+	limit, ok := lim.limits[endpoint]
+	if !ok {
+		return nil
+	}
+
+	lim.mu.Lock()
+	now := lim.clock.Now()
+	lim.rollover(now)
+
+	if limit.Daily > 0 && lim.usage.Counts[endpoint] >= limit.Daily {
+		lim.mu.Unlock()
+		return &LimitError{Endpoint: endpoint, Limit: limit.Daily, Daily: true, RetryAt: nextReset(now)}
+	}
+
+	wait := lim.reserve(endpoint, limit, now)
+	if limit.MaxWait > 0 && wait > limit.MaxWait {
+		lim.buckets[endpoint].tokens++
+		lim.mu.Unlock()
+		return &LimitError{Endpoint: endpoint, RetryAt: now.Add(wait)}
+	}
+
+	lim.usage.Counts[endpoint]++
+	lim.dirty = true
+	lim.mu.Unlock()
+
+	if wait > 0 {
+		if err := lim.clock.Sleep(ctx, wait); err != nil {
+			// Give back the call that was never made
+			lim.mu.Lock()
+			lim.buckets[endpoint].tokens++
+			if lim.usage.Counts[endpoint] > 0 {
+				lim.usage.Counts[endpoint]--
+				lim.dirty = true
+			}
+			lim.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// Takes a token from the endpoint's bucket and gets how long the caller has to wait for it. The bucket goes
+// negative while calls are queued so that they are spaced out in order. Must be called with lim.mu held.
+func (lim *limiter) reserve(endpoint string, limit RateLimit, now time.Time) time.Duration {
+	if limit.PerSecond <= 0 {
+		return 0
+	}
+
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	b, ok := lim.buckets[endpoint]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		lim.buckets[endpoint] = b
+	}
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * limit.PerSecond
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / limit.PerSecond * float64(time.Second))
+}
+
+// Saves the counts to the store if they changed since the last save. A failed save is logged and tried
+// again next time, since the counts are still enforced in memory.
+func (lim *limiter) save() {
+	if lim.store == nil {
+		return
+	}
+	lim.saveMu.Lock()
+	defer lim.saveMu.Unlock()
+
+	// Copy the counts so calls can go on counting while they are written
+	lim.mu.Lock()
+	if !lim.dirty {
+		lim.mu.Unlock()
+		return
+	}
+	usage := Usage{Day: lim.usage.Day, Counts: make(map[string]int, len(lim.usage.Counts))}
+	for endpoint, count := range lim.usage.Counts {
+		usage.Counts[endpoint] = count
+	}
+	lim.dirty = false
+	lim.mu.Unlock()
+
+	if err := lim.store.Save(usage); err != nil {
+		log.Printf("failed to save Kroger API usage: %v", err)
+		lim.mu.Lock()
+		lim.dirty = true
+		lim.mu.Unlock()
+	}
+}
+
+// Saves the counts on an interval until the limiter is closed
+func (lim *limiter) saveEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lim.save()
+		case <-lim.done:
+			return
+		}
+	}
+}
+
+// Stops saving in the background and saves any counts not yet saved
+func (lim *limiter) close() {
+	lim.once.Do(func() {
+		close(lim.done)
+	})
+	lim.save()
+}
+
+// Gets the usage of every limited endpoint, sorted by endpoint name
+func (lim *limiter) report() []models.EndpointUsage {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	now := lim.clock.Now()
+	lim.rollover(now)
+
+	report := []models.EndpointUsage{}
+	for endpoint, limit := range lim.limits {
+		usage := models.EndpointUsage{
+			Endpoint:   endpoint,
+			Used:       lim.usage.Counts[endpoint],
+			DailyLimit: limit.Daily,
+			PerSecond:  limit.PerSecond,
+			ResetsAt:   nextReset(now),
+		}
+		if limit.Daily > 0 {
+			usage.Remaining = limit.Daily - usage.Used
+			if usage.Remaining < 0 {
+				usage.Remaining = 0
+			}
+		}
+		report = append(report, usage)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Endpoint < report[j].Endpoint
+	})
+	return report
+}
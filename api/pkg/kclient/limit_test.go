@@ -0,0 +1,234 @@
+package kclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, limits map[string]RateLimit, store QuotaStore) (*limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)}
+	lim, err := newLimiter(limits, store, clock)
+	if err != nil {
+		t.Fatalf("error during limiter setup, %v", err)
+	}
+	t.Cleanup(lim.close)
+	return lim, clock
+}
+
+func TestEndpointOf(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"/products", EndpointProducts},
+		{"/products/0001111041700", EndpointProducts},
+		{"/locations/70100393", EndpointLocations},
+		{"/connect/oauth2/token", "connect"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if endpoint := endpointOf(tc.path); endpoint != tc.expected {
+				t.Errorf("expected %s but got %s", tc.expected, endpoint)
+			}
+		})
+	}
+}
+
+func TestLimiterThrottles(t *testing.T) {
+	lim, clock := newTestLimiter(t, map[string]RateLimit{EndpointProducts: {PerSecond: 2, Burst: 2}}, nil)
+
+	for i := 0; i < 4; i++ {
+		if err := lim.acquire(context.Background(), EndpointProducts); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+	}
+
+	// The burst is used up by the first two calls, after which each call waits for the next token
+	expected := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}
+	if len(clock.sleeps) != len(expected) {
+		t.Fatalf("expected sleeps %v but got %v", expected, clock.sleeps)
+	}
+	for i := range expected {
+		if clock.sleeps[i] != expected[i] {
+			t.Errorf("expected sleeps %v but got %v", expected, clock.sleeps)
+		}
+	}
+}
+
+func TestLimiterIgnoresUnlimitedEndpoints(t *testing.T) {
+	lim, clock := newTestLimiter(t, map[string]RateLimit{EndpointProducts: {PerSecond: 1, Burst: 1, Daily: 1}}, nil)
+
+	for i := 0; i < 3; i++ {
+		if err := lim.acquire(context.Background(), EndpointLocations); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+	}
+	if len(clock.sleeps) != 0 {
+		t.Errorf("expected no sleeps but got %v", clock.sleeps)
+	}
+}
+
+func TestLimiterMaxWait(t *testing.T) {
+	lim, _ := newTestLimiter(t, map[string]RateLimit{EndpointProducts: {PerSecond: 1, Burst: 1, MaxWait: 500 * time.Millisecond}}, nil)
+	if err := lim.acquire(context.Background(), EndpointProducts); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+
+	// The second call would have to wait a second for the next token
+	err := lim.acquire(context.Background(), EndpointProducts)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Daily {
+		t.Fatalf("expected a rate limit error but got %v", err)
+	} else if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected error to match ErrQuotaExceeded")
+	}
+	if used := lim.report()[0].Used; used != 1 {
+		t.Errorf("expected 1 call counted but got %d", used)
+	}
+}
+
+func TestLimiterDailyQuota(t *testing.T) {
+	lim, clock := newTestLimiter(t, map[string]RateLimit{EndpointProducts: {Daily: 2}}, nil)
+
+	for i := 0; i < 2; i++ {
+		if err := lim.acquire(context.Background(), EndpointProducts); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+	}
+
+	err := lim.acquire(context.Background(), EndpointProducts)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !limitErr.Daily {
+		t.Fatalf("expected a daily quota error but got %v", err)
+	} else if expected := time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC); !limitErr.RetryAt.Equal(expected) {
+		t.Errorf("expected retry at %v but got %v", expected, limitErr.RetryAt)
+	}
+
+	// The quota is reset the next day
+	clock.now = clock.now.Add(12 * time.Hour)
+	if err := lim.acquire(context.Background(), EndpointProducts); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	if usage := lim.report()[0]; usage.Used != 1 || usage.Remaining != 1 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
+
+func TestLimiterCancelledWhileQueued(t *testing.T) {
+	lim, _ := newTestLimiter(t, map[string]RateLimit{EndpointProducts: {PerSecond: 1, Burst: 1}}, nil)
+	if err := lim.acquire(context.Background(), EndpointProducts); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := lim.acquire(ctx, EndpointProducts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled but got %v", err)
+	}
+	if used := lim.report()[0].Used; used != 1 {
+		t.Errorf("expected the cancelled call not to be counted but got %d calls", used)
+	}
+}
+
+func TestFileQuotaStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	limits := map[string]RateLimit{EndpointProducts: {Daily: 10}}
+
+	lim, _ := newTestLimiter(t, limits, NewFileQuotaStore(path))
+	for i := 0; i < 3; i++ {
+		if err := lim.acquire(context.Background(), EndpointProducts); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+	}
+	lim.close()
+
+	// A new limiter picks up the saved counts
+	lim, clock := newTestLimiter(t, limits, NewFileQuotaStore(path))
+	if usage := lim.report()[0]; usage.Used != 3 || usage.Remaining != 7 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+
+	// Counts saved on an earlier day are discarded
+	clock.now = clock.now.Add(24 * time.Hour)
+	if usage := lim.report()[0]; usage.Used != 0 {
+		t.Errorf("expected counts to be reset but got %+v", usage)
+	}
+}
+
+// QuotaStore that counts its saves
+type countingQuotaStore struct {
+	mu    sync.Mutex
+	saves int
+	last  Usage
+}
+
+func (store *countingQuotaStore) Load() (Usage, error) {
+	return Usage{}, nil
+}
+
+func (store *countingQuotaStore) Save(usage Usage) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.saves++
+	store.last = usage
+	return nil
+}
+
+func TestLimiterSavesInBackground(t *testing.T) {
+	store := &countingQuotaStore{}
+	lim, _ := newTestLimiter(t, map[string]RateLimit{EndpointProducts: {Daily: 10}}, store)
+
+	// Calls are counted in memory without waiting on the store
+	for i := 0; i < 5; i++ {
+		if err := lim.acquire(context.Background(), EndpointProducts); err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		}
+	}
+	if store.saves != 0 {
+		t.Errorf("expected no saves while calling but got %d", store.saves)
+	}
+	if usage := lim.report()[0]; usage.Used != 5 {
+		t.Errorf("expected 5 calls counted but got %d", usage.Used)
+	}
+
+	// Changed counts are saved once, and unchanged counts are not saved again
+	lim.save()
+	lim.save()
+	if store.saves != 1 || store.last.Counts[EndpointProducts] != 5 {
+		t.Errorf("expected one save of 5 calls but got %d saves of %+v", store.saves, store.last)
+	}
+
+	// Closing saves the counts made since
+	if err := lim.acquire(context.Background(), EndpointProducts); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	lim.close()
+	if store.saves != 2 || store.last.Counts[EndpointProducts] != 6 {
+		t.Errorf("expected the counts to be saved on close but got %d saves of %+v", store.saves, store.last)
+	}
+}
+
+func TestDoRejectsWhenQuotaUsedUp(t *testing.T) {
+	server, calls := newStatusServer(t, []int{http.StatusOK}, nil)
+	clock := &fakeClock{now: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)}
+	client, err := New(server.URL, "id", "secret", "FRED", WithClock(clock), WithRateLimits(map[string]RateLimit{EndpointProducts: {Daily: 1}}))
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk"}); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected quota error but got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("expected 1 call to reach the server but got %d", *calls)
+	}
+}
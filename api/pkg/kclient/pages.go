@@ -0,0 +1,137 @@
+package kclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Limits Kroger puts on paging through product search results
+const (
+	// Largest number of products returned by a single request
+	MaxProductLimit = 50
+	// Largest number of products that can be skipped, so results past MaxProductStart + MaxProductLimit can
+	// never be reached
+	MaxProductStart = 1000
+)
+
+// Returned by a WalkProducts callback to stop walking without failing
+var ErrStopWalk = errors.New("stop walking products")
+
+// Controls how WalkProducts pages through search results
+type WalkOptions struct {
+	// Products requested per page, 1 to 50. Zero uses MaxProductLimit.
+	PageSize int
+	// Stops after this many products. Zero walks every page Kroger allows.
+	Max int
+	// Number of pages requested at once after the first one. Values below 2 request pages one at a time.
+	Concurrency int
+}
+
+// Calls fn with each page of products matching the query, in order, starting at query.Start. The first page
+// is requested on its own to learn the total from its pagination metadata, after which the remaining pages
+// are requested up to opts.Concurrency at a time. Walking stops at the end of the results, at the offset
+// ceiling, after opts.Max products, when fn returns an error or when ctx is done. Returning ErrStopWalk from
+// fn stops walking without an error. The last page is trimmed so that no more than opts.Max products are seen.
+func WalkProducts(ctx context.Context, client GroceryClient, query ProductQuery, opts WalkOptions, fn func(page *models.ProductsResponse) error) error {
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = MaxProductLimit
+	}
+	query.Limit = pageSize
+	if err := query.Validate(); err != nil {
+		return err
+	} else if opts.Max < 0 {
+		return newValidationError("parameter 'max' value %d is invalid. Must not be negative", opts.Max)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	walked := 0
+	deliver := func(page *models.ProductsResponse) (bool, error) {
+		full := len(page.Data) >= pageSize
+		if opts.Max > 0 && walked+len(page.Data) > opts.Max {
+			page.Data = page.Data[:opts.Max-walked]
+		}
+		walked += len(page.Data)
+
+		if err := fn(page); err != nil {
+			return true, err
+		}
+		return !full || (opts.Max > 0 && walked >= opts.Max), nil
+	}
+
+	first, err := client.GetProducts(ctx, query)
+	if err != nil {
+		return err
+	}
+	if done, err := deliver(first); done {
+		return stopWalk(err)
+	}
+
+	starts := pageStarts(query.Start, pageSize, first.Meta.Pagination.Total, opts.Max)
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	for len(starts) > 0 {
+		batch := starts
+		if len(batch) > concurrency {
+			batch = batch[:concurrency]
+		}
+		starts = starts[len(batch):]
+
+		pages := make([]*models.ProductsResponse, len(batch))
+		errs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for i, start := range batch {
+			wg.Add(1)
+			go func(i int, start int) {
+				defer wg.Done()
+				pageQuery := query
+				pageQuery.Start = start
+				pages[i], errs[i] = client.GetProducts(ctx, pageQuery)
+			}(i, start)
+		}
+		wg.Wait()
+
+		// Deliver the batch in order, stopping at the first failure
+		for i := range batch {
+			if errs[i] != nil {
+				return errs[i]
+			}
+			if done, err := deliver(pages[i]); done {
+				return stopWalk(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Gets the offsets of the pages after the first one that are needed to reach total products, or max products
+// when that is smaller, without going past the offset ceiling
+func pageStarts(start int, pageSize int, total int, max int) []int {
+	end := total
+	if max > 0 && start+max < end {
+		end = start + max
+	}
+
+	var starts []int
+	for next := start + pageSize; next < end && next <= MaxProductStart; next += pageSize {
+		starts = append(starts, next)
+	}
+	return starts
+}
+
+// Converts the error that stopped a walk into the error WalkProducts returns
+func stopWalk(err error) error {
+	if errors.Is(err, ErrStopWalk) {
+		return nil
+	}
+	return err
+}
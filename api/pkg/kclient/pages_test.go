@@ -0,0 +1,147 @@
+package kclient
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+func TestPageStarts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		start    int
+		pageSize int
+		total    int
+		max      int
+		expected []int
+	}{
+		{"single page", 0, 50, 20, 0, nil},
+		{"exact pages", 0, 10, 30, 0, []int{10, 20}},
+		{"partial last page", 5, 10, 30, 0, []int{15, 25}},
+		{"max", 0, 10, 100, 25, []int{10, 20}},
+		{"offset ceiling", 900, 50, 5000, 0, []int{950, 1000}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if starts := pageStarts(tc.start, tc.pageSize, tc.total, tc.max); !reflect.DeepEqual(starts, tc.expected) {
+				t.Errorf("expected %v but got %v", tc.expected, starts)
+			}
+		})
+	}
+}
+
+// Walks the Kroger brand products in pages of two and gets their IDs in the order they were seen
+func walkKrogerBrand(t *testing.T, opts WalkOptions) ([]string, error) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	var ids []string
+	opts.PageSize = 2
+	err = WalkProducts(context.Background(), client, ProductQuery{Brand: "Kroger"}, opts, func(page *models.ProductsResponse) error {
+		for _, product := range page.Data {
+			ids = append(ids, product.ProductId)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func TestWalkProducts(t *testing.T) {
+	sequential, err := walkKrogerBrand(t, WalkOptions{Max: 7})
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if len(sequential) < 3 {
+		t.Fatalf("expected products from several pages but got %d", len(sequential))
+	}
+
+	concurrent, err := walkKrogerBrand(t, WalkOptions{Max: 7, Concurrency: 3})
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if !reflect.DeepEqual(sequential, concurrent) {
+		t.Errorf("expected concurrent walk to see %v but got %v", sequential, concurrent)
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range sequential {
+		if seen[id] {
+			t.Errorf("product %s was seen more than once", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestWalkProductsMax(t *testing.T) {
+	ids, err := walkKrogerBrand(t, WalkOptions{Max: 3, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if len(ids) != 3 {
+		t.Errorf("expected 3 products but got %d", len(ids))
+	}
+}
+
+func TestWalkProductsStop(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	pages := 0
+	err = WalkProducts(context.Background(), client, ProductQuery{Brand: "Kroger"}, WalkOptions{PageSize: 2}, func(page *models.ProductsResponse) error {
+		pages++
+		return ErrStopWalk
+	})
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if pages != 1 {
+		t.Errorf("expected 1 page but got %d", pages)
+	}
+}
+
+func TestWalkProductsCancelled(t *testing.T) {
+	client, err := New(cfg.KrogerApiBaseUrl, cfg.KrogerApiClientId, cfg.KrogerApiClientSecret, cfg.KrogerApiChain)
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := 0
+	err = WalkProducts(ctx, client, ProductQuery{Brand: "Kroger"}, WalkOptions{PageSize: 2, Concurrency: 2}, func(page *models.ProductsResponse) error {
+		pages++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled but got %v", err)
+	} else if pages != 1 {
+		t.Errorf("expected 1 page but got %d", pages)
+	}
+}
+
+func TestWalkProductsInvalidParam(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query ProductQuery
+		opts  WalkOptions
+	}{
+		{"no filter", ProductQuery{}, WalkOptions{}},
+		{"page size", ProductQuery{Term: "milk"}, WalkOptions{PageSize: 51}},
+		{"max", ProductQuery{Term: "milk"}, WalkOptions{Max: -1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := WalkProducts(context.Background(), nil, tc.query, tc.opts, func(page *models.ProductsResponse) error {
+				return nil
+			})
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("expected validation error but got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package kclient
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// A point given by latitude and longitude in decimal degrees
+type Coordinates struct {
+	Lat float64
+	Lon float64
+}
+
+// Filters for a Kroger location search. Exactly one of ZipCode, Near or LatLong must be given unless the
+// search is for specific LocationIds.
+type LocationQuery struct {
+	// Five digit zip code to search near
+	ZipCode string
+	// Latitude and longitude to search near
+	Near *Coordinates
+	// Comma separated latitude and longitude to search near, e.g. "45.4347,-122.7636"
+	LatLong string
+	// Search radius, 1 to 100. Zero uses Kroger's default of 10 miles.
+	RadiusInMiles int
+	// Maximum number of results, 1 to 200. Zero uses Kroger's default.
+	Limit int
+	// Only include stores that have all of these departments
+	DepartmentIds []string
+	// Chain to search instead of the client's configured chain
+	Chain string
+	// Only include these stores
+	LocationIds []string
+}
+
+// Checks that the query is valid without sending it
+func (query LocationQuery) Validate() error {
+	_, err := query.values("")
+	return err
+}
+
+// Validates the query and converts it to Kroger API query parameters. The given chain is used unless the
+// query overrides it.
+func (query LocationQuery) values(defaultChain string) (url.Values, error) {
+	origins := 0
+	for _, given := range []bool{query.ZipCode != "", query.Near != nil, query.LatLong != ""} {
+		if given {
+			origins++
+		}
+	}
+
+	if origins == 0 && len(query.LocationIds) == 0 {
+		return nil, newValidationError("one of the parameters 'zipCode', 'lat' and 'lon', 'latLong' or 'locationIds' is required")
+	} else if origins > 1 {
+		return nil, newValidationError("only one of the parameters 'zipCode', 'lat' and 'lon' or 'latLong' may be given")
+	} else if digits := countDigits(query.ZipCode); query.ZipCode != "" && (digits != 5 || digits != len(query.ZipCode)) {
+		return nil, newValidationError("parameter 'zipCode' value '%s' is invalid. Must be a number with 5 digits", query.ZipCode)
+	} else if query.Near != nil && (!finite(query.Near.Lat) || query.Near.Lat < -90 || query.Near.Lat > 90) {
+		return nil, newValidationError("parameter 'lat' value %g is invalid. Valid values are -90 to 90", query.Near.Lat)
+	} else if query.Near != nil && (!finite(query.Near.Lon) || query.Near.Lon < -180 || query.Near.Lon > 180) {
+		return nil, newValidationError("parameter 'lon' value %g is invalid. Valid values are -180 to 180", query.Near.Lon)
+	} else if query.LatLong != "" && !validLatLong(query.LatLong) {
+		return nil, newValidationError("parameter 'latLong' value '%s' is invalid. Must be a latitude and longitude separated by a comma", query.LatLong)
+	} else if query.RadiusInMiles < 0 || query.RadiusInMiles > 100 {
+		return nil, newValidationError("parameter 'radiusInMiles' value %d is invalid. Valid values are 0 to 100", query.RadiusInMiles)
+	} else if query.Limit < 0 || query.Limit > 200 {
+		return nil, newValidationError("parameter 'filterLimit' value %d is invalid. Valid values are 0 to 200", query.Limit)
+	} else if hasEmpty(query.DepartmentIds) {
+		return nil, newValidationError("parameter 'departmentIds' contains an empty value")
+	} else if hasEmpty(query.LocationIds) {
+		return nil, newValidationError("parameter 'locationIds' contains an empty value")
+	}
+
+	values := url.Values{}
+	if query.Chain != "" {
+		values.Set("filter.chain", query.Chain)
+	} else {
+		values.Set("filter.chain", defaultChain)
+	}
+
+	switch {
+	case query.ZipCode != "":
+		values.Set("filter.zipCode.near", query.ZipCode)
+	case query.Near != nil:
+		values.Set("filter.lat.near", strconv.FormatFloat(query.Near.Lat, 'f', -1, 64))
+		values.Set("filter.lon.near", strconv.FormatFloat(query.Near.Lon, 'f', -1, 64))
+	case query.LatLong != "":
+		values.Set("filter.latLong.near", strings.ReplaceAll(query.LatLong, " ", ""))
+	}
+
+	if query.RadiusInMiles > 0 {
+		values.Set("filter.radiusInMiles", strconv.Itoa(query.RadiusInMiles))
+	}
+
+	if query.Limit > 0 {
+		values.Set("filter.limit", strconv.Itoa(query.Limit))
+	}
+
+	if len(query.DepartmentIds) > 0 {
+		values.Set("filter.department", strings.Join(query.DepartmentIds, ","))
+	}
+
+	if len(query.LocationIds) > 0 {
+		values.Set("filter.locationId", strings.Join(query.LocationIds, ","))
+	}
+
+	return values, nil
+}
+
+// Ways a product can be obtained, used to filter a product search
+const (
+	FulfillmentInStore    = "ais"
+	FulfillmentCurbside   = "csp"
+	FulfillmentDelivery   = "dth"
+	FulfillmentShipToHome = "sth"
+)
+
+// Filters for a Kroger product search. At least one of Term, Brand or ProductIds must be given.
+type ProductQuery struct {
+	// Free text search term
+	Term string
+	// Store to get aisle locations, stock levels and pricing for
+	LocationId string
+	// Only include products of this brand, e.g. "Kroger"
+	Brand string
+	// Only include products available with this fulfillment type at the store. Requires LocationId.
+	Fulfillment string
+	// Only include these products
+	ProductIds []string
+	// Number of results to skip, 0 to 1000
+	Start int
+	// Maximum number of results, 0 to 50. Zero uses Kroger's default.
+	Limit int
+}
+
+// Checks that the query is valid without sending it
+func (query ProductQuery) Validate() error {
+	_, err := query.values()
+	return err
+}
+
+// Validates the query and converts it to Kroger API query parameters
+func (query ProductQuery) values() (url.Values, error) {
+	if query.Term == "" && query.Brand == "" && len(query.ProductIds) == 0 {
+		return nil, newValidationError("one of the parameters 'filterTerm', 'brand' or 'productIds' is required")
+	} else if query.Start < 0 || query.Start > MaxProductStart {
+		return nil, newValidationError("parameter 'filterOffset' value %d is invalid. Valid values are 0 to 1000", query.Start)
+	} else if query.Limit < 0 || query.Limit > MaxProductLimit {
+		return nil, newValidationError("parameter 'filterLimit' value %d is invalid. Valid values are 0 to 50", query.Limit)
+	} else if query.Fulfillment != "" && !validFulfillment(query.Fulfillment) {
+		return nil, newValidationError("parameter 'fulfillment' value '%s' is invalid. Valid values are ais, csp, dth and sth", query.Fulfillment)
+	} else if query.Fulfillment != "" && query.LocationId == "" {
+		return nil, newValidationError("parameter 'fulfillment' requires a 'locationId'")
+	} else if hasEmpty(query.ProductIds) {
+		return nil, newValidationError("parameter 'productIds' contains an empty value")
+	}
+
+	values := url.Values{}
+	if query.Term != "" {
+		values.Set("filter.term", query.Term)
+	}
+
+	if query.LocationId != "" {
+		values.Set("filter.locationId", query.LocationId)
+	}
+
+	if query.Brand != "" {
+		values.Set("filter.brand", query.Brand)
+	}
+
+	if query.Fulfillment != "" {
+		values.Set("filter.fulfillment", query.Fulfillment)
+	}
+
+	if len(query.ProductIds) > 0 {
+		values.Set("filter.productId", strings.Join(query.ProductIds, ","))
+	}
+
+	if query.Start > 0 {
+		values.Set("filter.start", strconv.Itoa(query.Start))
+	}
+
+	if query.Limit > 0 {
+		values.Set("filter.limit", strconv.Itoa(query.Limit))
+	}
+
+	return values, nil
+}
+
+// Checks whether a fulfillment type is one that Kroger supports
+func validFulfillment(fulfillment string) bool {
+	switch fulfillment {
+	case FulfillmentInStore, FulfillmentCurbside, FulfillmentDelivery, FulfillmentShipToHome:
+		return true
+	}
+	return false
+}
+
+// Checks that a string is a valid "latitude,longitude" pair
+func validLatLong(latLong string) bool {
+	parts := strings.Split(strings.ReplaceAll(latLong, " ", ""), ",")
+	if len(parts) != 2 {
+		return false
+	}
+
+	lat, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || !finite(lat) || lat < -90 || lat > 90 {
+		return false
+	}
+
+	lon, err := strconv.ParseFloat(parts[1], 64)
+	return err == nil && finite(lon) && lon >= -180 && lon <= 180
+}
+
+// Checks whether any of the strings is empty
+func hasEmpty(values []string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that a number is neither NaN nor infinite, which strconv.ParseFloat accepts
+func finite(number float64) bool {
+	return !math.IsNaN(number) && !math.IsInf(number, 0)
+}
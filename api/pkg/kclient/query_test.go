@@ -0,0 +1,116 @@
+package kclient
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLocationQueryValues(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    LocationQuery
+		expected string
+	}{
+		{"zip code", LocationQuery{ZipCode: "97224", Limit: 10}, "filter.chain=FRED&filter.limit=10&filter.zipCode.near=97224"},
+		{"coordinates", LocationQuery{Near: &Coordinates{Lat: 45.4347, Lon: -122.7636}, RadiusInMiles: 5}, "filter.chain=FRED&filter.lat.near=45.4347&filter.lon.near=-122.7636&filter.radiusInMiles=5"},
+		{"lat long", LocationQuery{LatLong: "45.4347, -122.7636"}, "filter.chain=FRED&filter.latLong.near=45.4347%2C-122.7636"},
+		{"departments and chain", LocationQuery{ZipCode: "97224", DepartmentIds: []string{"09", "13"}, Chain: "KROGER"}, "filter.chain=KROGER&filter.department=09%2C13&filter.zipCode.near=97224"},
+		{"location ids only", LocationQuery{LocationIds: []string{"70100393", "70100394"}}, "filter.chain=FRED&filter.locationId=70100393%2C70100394"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := tc.query.values("FRED")
+			if err != nil {
+				t.Fatalf("expected success but got error, %v", err)
+			}
+			if actual := values.Encode(); actual != tc.expected {
+				t.Errorf("expected %s but got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLocationQueryValuesInvalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query LocationQuery
+	}{
+		{"no origin", LocationQuery{Limit: 10}},
+		{"two origins", LocationQuery{ZipCode: "97224", LatLong: "45.4,-122.7"}},
+		{"zipCode too long", LocationQuery{ZipCode: "123456"}},
+		{"zipCode too short", LocationQuery{ZipCode: "1234"}},
+		{"zipCode not a number", LocationQuery{ZipCode: "9722A"}},
+		{"lat out of range", LocationQuery{Near: &Coordinates{Lat: 91, Lon: 0}}},
+		{"lon out of range", LocationQuery{Near: &Coordinates{Lat: 0, Lon: -181}}},
+		{"lat not a number", LocationQuery{Near: &Coordinates{Lat: math.NaN(), Lon: 0}}},
+		{"lon not a number", LocationQuery{Near: &Coordinates{Lat: 0, Lon: math.NaN()}}},
+		{"lat infinite", LocationQuery{Near: &Coordinates{Lat: math.Inf(1), Lon: 0}}},
+		{"latLong not finite", LocationQuery{LatLong: "NaN,NaN"}},
+		{"latLong infinite", LocationQuery{LatLong: "45.4,-Inf"}},
+		{"latLong malformed", LocationQuery{LatLong: "45.4"}},
+		{"latLong not numbers", LocationQuery{LatLong: "north,west"}},
+		{"radius too high", LocationQuery{ZipCode: "97224", RadiusInMiles: 101}},
+		{"limit too low", LocationQuery{ZipCode: "97224", Limit: -1}},
+		{"limit too high", LocationQuery{ZipCode: "97224", Limit: 1000}},
+		{"empty department", LocationQuery{ZipCode: "97224", DepartmentIds: []string{"09", ""}}},
+		{"empty location id", LocationQuery{LocationIds: []string{" "}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.query.values("FRED"); !errors.Is(err, ErrValidation) {
+				t.Errorf("expected a validation error but got %v", err)
+			}
+		})
+	}
+}
+
+func TestProductQueryValues(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    ProductQuery
+		expected string
+	}{
+		{"term", ProductQuery{Term: "milk", LocationId: "70100393", Start: 10, Limit: 5}, "filter.limit=5&filter.locationId=70100393&filter.start=10&filter.term=milk"},
+		{"brand and fulfillment", ProductQuery{Term: "milk", Brand: "Kroger", Fulfillment: FulfillmentCurbside, LocationId: "70100393"}, "filter.brand=Kroger&filter.fulfillment=csp&filter.locationId=70100393&filter.term=milk"},
+		{"brand without term", ProductQuery{Brand: "Kroger"}, "filter.brand=Kroger"},
+		{"product ids without term", ProductQuery{ProductIds: []string{"0001111041700", "0001111041600"}}, "filter.productId=0001111041700%2C0001111041600"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := tc.query.values()
+			if err != nil {
+				t.Fatalf("expected success but got error, %v", err)
+			}
+			if actual := values.Encode(); actual != tc.expected {
+				t.Errorf("expected %s but got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestProductQueryValuesInvalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query ProductQuery
+	}{
+		{"nothing to search for", ProductQuery{LocationId: "70100393"}},
+		{"offset too low", ProductQuery{Term: "milk", Start: -1}},
+		{"offset too high", ProductQuery{Term: "milk", Start: 1001}},
+		{"limit too high", ProductQuery{Term: "milk", Limit: 51}},
+		{"fulfillment unknown", ProductQuery{Term: "milk", LocationId: "70100393", Fulfillment: "drone"}},
+		{"fulfillment without location", ProductQuery{Term: "milk", Fulfillment: FulfillmentDelivery}},
+		{"empty product id", ProductQuery{ProductIds: []string{""}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.query.values(); !errors.Is(err, ErrValidation) {
+				t.Errorf("expected a validation error but got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package kclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Describes a single call to the Kroger API
+type apiRequest struct {
+	method string
+	// Path relative to the client's base URL
+	path  string
+	query url.Values
+	// Form body, only sent by the token request which authenticates with the client credentials instead of
+	// a bearer token
+	form url.Values
+}
+
+// Largest response body read when a request fails, which is only used for the error message
+const maxErrorBodyBytes = 64 << 10
+
+// Most of a response body that is read and discarded so its connection can be reused. Connections with more
+// left unread are closed instead, since reading it all would take longer than opening a new one.
+const maxDrainBytes = 256 << 10
+
+// The outcome of a single attempt at an API request. The body is only read for unsuccessful responses.
+type apiResponse struct {
+	statusCode int
+	status     string
+	header     http.Header
+	body       []byte
+}
+
+// Executes a request against the Kroger API and decodes a successful JSON response into out. Transient
+// failures are retried according to the client's RetryPolicy, and a request rejected with a 401 is retried
+// once with a new token in case the cached one was revoked. Each attempt's response body is closed before the
+// next attempt is made. Every attempt other than a token request goes through the client's rate limiter first.
+func (client *KClient) do(ctx context.Context, req apiRequest, out interface{}) error {
+	var reauthorized = false
+	for attempt := 1; ; attempt++ {
+		var token string
+		if req.form == nil {
+			var err error
+			if token, err = client.auth.Token(ctx); err != nil {
+				return fmt.Errorf("authorization failed: %w", err)
+			}
+			if err := client.limiter.acquire(ctx, endpointOf(req.path)); err != nil {
+				return err
+			}
+		}
+
+		res, err := client.send(ctx, req, token, out)
+		if err != nil {
+			if attempt < client.retry.attempts() && client.retry.retryableError(err) {
+				if err := client.clock.Sleep(ctx, client.retry.backoff(attempt, client.random)); err != nil {
+					return err
+				}
+				continue // Retry on network errors
+			}
+			return err
+		}
+
+		switch {
+		case res.statusCode == http.StatusOK:
+			return nil
+		case res.statusCode == http.StatusUnauthorized && req.form == nil && !reauthorized:
+			client.auth.Invalidate(token)
+			reauthorized = true
+			attempt--
+			continue // Retry once with a new token without counting it as a failed attempt
+		case attempt < client.retry.attempts() && client.retry.retryableStatus(res.statusCode):
+			delay, ok := client.retryDelay(attempt, res)
+			if !ok {
+				break
+			}
+			if err := client.clock.Sleep(ctx, delay); err != nil {
+				return err
+			}
+			continue // Retry on transient server errors
+		}
+
+		return getResponseError(res.statusCode, res.status, res.body)
+	}
+}
+
+// Gets how long to wait before retrying a failed response, and false if the response should not be retried
+// because the server asked for a longer wait than the policy allows
+func (client *KClient) retryDelay(attempt int, res *apiResponse) (time.Duration, bool) {
+	if client.retry.RespectRetryAfter {
+		if delay, ok := parseRetryAfter(res.header.Get("Retry-After"), client.clock.Now()); ok {
+			if client.retry.MaxDelay > 0 && delay > client.retry.MaxDelay {
+				return 0, false
+			}
+			return delay, true
+		}
+	}
+	return client.retry.backoff(attempt, client.random), true
+}
+
+// Sends a single attempt of a request. A successful response is decoded into out as it is streamed, and
+// the body of any other response is read for its error message. The body is drained and closed before
+// returning either way. Requests without a form body are authorized with the given bearer token.
+func (client *KClient) send(ctx context.Context, req apiRequest, token string, out interface{}) (*apiResponse, error) {
+	reqUrl := client.baseUrl + req.path
+	if len(req.query) > 0 {
+		reqUrl = fmt.Sprintf("%s?%s", reqUrl, req.query.Encode())
+	}
+
+	var body io.Reader
+	if req.form != nil {
+		body = strings.NewReader(req.form.Encode())
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, reqUrl, body)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if req.form != nil {
+		httpReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		httpReq.Header.Add("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", client.id, client.secret)))))
+	} else {
+		httpReq.Header.Add("Accept", "application/json")
+		httpReq.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	res, err := client.netClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer closeBody(res.Body)
+
+	apiRes := &apiResponse{
+		statusCode: res.StatusCode,
+		status:     res.Status,
+		header:     res.Header,
+	}
+
+	if res.StatusCode == http.StatusOK {
+		if err := decodeBody(res.Body, client.maxResponseBytes, out); err != nil {
+			return nil, err
+		}
+		return apiRes, nil
+	}
+
+	if apiRes.body, err = ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBodyBytes)); err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return apiRes, nil
+}
+
+// Decodes a JSON body into out as it is read, failing with ErrResponseTooLarge if the body is longer than
+// maxBytes. Zero or less means the body's size is not limited.
+func decodeBody(body io.Reader, maxBytes int64, out interface{}) error {
+	var limited *io.LimitedReader
+	if maxBytes > 0 {
+		// Allow one byte more than the limit so a body of exactly maxBytes is not mistaken for a longer one
+		limited = &io.LimitedReader{R: body, N: maxBytes + 1}
+		body = limited
+	}
+
+	err := json.NewDecoder(body).Decode(out)
+	if limited != nil && limited.N <= 0 {
+		return fmt.Errorf("%w: body is longer than %d bytes", ErrResponseTooLarge, maxBytes)
+	} else if err != nil {
+		return fmt.Errorf("failed to deserialize JSON response body: %w", err)
+	}
+	return nil
+}
+
+// Reads what is left of a response body, up to maxDrainBytes, and closes it so the connection can be reused
+func closeBody(body io.ReadCloser) {
+	_, _ = io.CopyN(ioutil.Discard, body, maxDrainBytes)
+	body.Close()
+}
@@ -0,0 +1,220 @@
+package kclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+func TestDecodeBody(t *testing.T) {
+	body := `{"data":[],"meta":{"pagination":{"total":0,"start":0,"limit":1}}}`
+	testCases := []struct {
+		name     string
+		body     string
+		maxBytes int64
+		tooLarge bool
+		invalid  bool
+	}{
+		{"no limit", body, 0, false, false},
+		{"within limit", body, 1000, false, false},
+		{"exactly the limit", body, int64(len(body)), false, false},
+		{"over the limit", body, int64(len(body)) - 1, true, false},
+		{"malformed", `{"data":[`, 1000, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp models.ProductsResponse
+			err := decodeBody(strings.NewReader(tc.body), tc.maxBytes, &resp)
+			switch {
+			case tc.tooLarge:
+				if !errors.Is(err, ErrResponseTooLarge) {
+					t.Errorf("expected ErrResponseTooLarge but got %v", err)
+				}
+			case tc.invalid:
+				if err == nil {
+					t.Errorf("expected err but got none")
+				}
+			case err != nil:
+				t.Errorf("expected success but got error, %v", err)
+			}
+		})
+	}
+}
+
+func TestResponseTooLargeNotRetried(t *testing.T) {
+	var calls int
+	server := newPageServer(t, productsPage(5), &calls)
+	client, err := New(server.URL, "id", "secret", "FRED", WithClock(&fakeClock{}), WithMaxResponseBytes(1024))
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk"}); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge but got %v", err)
+	} else if calls != 1 {
+		t.Errorf("expected 1 call but got %d", calls)
+	}
+}
+
+func TestConnectionReusedAcrossRetries(t *testing.T) {
+	// Fail twice with error bodies longer than the client reads for the error message
+	var calls int
+	errorBody := bytes.Repeat([]byte(" "), 2*maxErrorBodyBytes)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/connect/oauth2/token" {
+			w.Write([]byte(`{"access_token":"abc","expires_in":1800,"token_type":"bearer"}`))
+			return
+		}
+		if calls++; calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write(errorBody)
+			return
+		}
+		w.Write([]byte(`{"data":[],"meta":{"pagination":{"total":0,"start":0,"limit":1}}}`))
+	}))
+	defer server.Close()
+
+	// Count the connections the client opens to check that every attempt was sent over the same one
+	var mu sync.Mutex
+	var conns int
+	var dialer net.Dialer
+	transport := &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		mu.Lock()
+		conns++
+		mu.Unlock()
+		return dialer.DialContext(ctx, network, addr)
+	}}
+	defer transport.CloseIdleConnections()
+
+	client, err := New(server.URL, "id", "secret", "FRED", WithClock(&fakeClock{}), WithHttpClient(&http.Client{Transport: transport}))
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk"}); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if calls != 3 {
+		t.Errorf("expected 3 calls but got %d", calls)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 1 {
+		t.Errorf("expected 1 connection but got %d", conns)
+	}
+}
+
+// Builds the JSON for a page of products with store details, the largest response the client handles
+func productsPage(products int) []byte {
+	var page models.ProductsResponse
+	for i := 0; i < products; i++ {
+		var product models.Product
+		json.Unmarshal([]byte(fmt.Sprintf(`{
+			"productId": "%013d",
+			"aisleLocations": [{"bayNumber": "4", "description": "DAIRY", "number": "24", "numberOfFacings": "3", "sequenceNumber": "1", "side": "L", "shelfNumber": "3", "shelfPositionInBay": "2"}],
+			"brand": "Kroger",
+			"categories": ["Dairy"],
+			"countryOrigin": "United States",
+			"description": "Kroger 2%% Reduced Fat Milk",
+			"items": [{
+				"itemId": "%013d",
+				"inventory": {"stockLevel": "HIGH"},
+				"fulfillment": {"curbside": true, "delivery": true, "instore": true},
+				"price": {"regular": 2.99, "promo": 2.49, "regularPerUnitEstimate": 2.99, "promoPerUnitEstimate": 2.49},
+				"nationalPrice": {"regular": 3.19, "promo": 0},
+				"size": "1 gal",
+				"soldBy": "UNIT"
+			}],
+			"itemInformation": {"depth": "4.0", "height": "10.0", "width": "4.0"},
+			"temperature": {"indicator": "Refrigerated"},
+			"images": [{"id": "front", "perspective": "front", "default": true, "sizes": [
+				{"id": "s", "size": "small", "url": "https://www.kroger.com/product/images/small/front/0001111041700"},
+				{"id": "m", "size": "medium", "url": "https://www.kroger.com/product/images/medium/front/0001111041700"},
+				{"id": "l", "size": "large", "url": "https://www.kroger.com/product/images/large/front/0001111041700"}
+			]}],
+			"upc": "%013d"
+		}`, i, i, i)), &product)
+		page.Data = append(page.Data, product)
+	}
+	page.Meta.Pagination.Total = products
+	page.Meta.Pagination.Limit = products
+
+	body, _ := json.Marshal(page)
+	return body
+}
+
+// Measures decoding a body four times longer than the limit. Read whole and then decoded, the memory used grows
+// with the body, while decodeBody stops reading and fails once the limit is passed.
+func BenchmarkDecodeOversizedBody(b *testing.B) {
+	maxBytes := int64(len(productsPage(MaxProductLimit)))
+	body := productsPage(4 * MaxProductLimit)
+
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := ioutil.ReadAll(bytes.NewReader(body))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var resp models.ProductsResponse
+			if err := json.Unmarshal(data, &resp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("DecodeBody", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var resp models.ProductsResponse
+			if err := decodeBody(bytes.NewReader(body), maxBytes, &resp); !errors.Is(err, ErrResponseTooLarge) {
+				b.Fatalf("expected ErrResponseTooLarge but got %v", err)
+			}
+		}
+	})
+}
+
+// Serves a token and then the given body for every API request, counting the API requests
+func newPageServer(t testing.TB, body []byte, calls *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/connect/oauth2/token" {
+			w.Write([]byte(`{"access_token":"abc","expires_in":1800,"token_type":"bearer"}`))
+			return
+		}
+		*calls++
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func BenchmarkGetProductsPage(b *testing.B) {
+	var calls int
+	body := productsPage(MaxProductLimit)
+	server := newPageServer(b, body, &calls)
+
+	client, err := New(server.URL, "id", "secret", "FRED", WithRateLimits(nil))
+	if err != nil {
+		b.Fatalf("error during client setup, %v", err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", Limit: MaxProductLimit}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
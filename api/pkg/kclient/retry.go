@@ -0,0 +1,147 @@
+package kclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Controls how requests to the Kroger API are retried when they fail with a transient error
+type RetryPolicy struct {
+	// Total number of attempts including the first one. Values below 1 are treated as 1.
+	MaxAttempts int
+	// Delay before the first retry, doubled for every retry after that
+	BaseDelay time.Duration
+	// Upper bound for the computed backoff delay and for any Retry-After delay that is honored
+	MaxDelay time.Duration
+	// Waits a random duration between zero and the computed backoff ("full jitter") so that clients that
+	// failed together do not retry together
+	Jitter bool
+	// Response status codes that are retried
+	RetryableStatus []int
+	// Retries requests that failed without a response, e.g. connection resets or timeouts
+	RetryNetworkErrors bool
+	// Waits for the duration given in a Retry-After response header instead of the computed backoff. If the
+	// server asks for a longer wait than MaxDelay the request is not retried.
+	RespectRetryAfter bool
+}
+
+// Gets the retry policy used by clients that are not given one
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    20 * time.Second,
+		Jitter:      true,
+		RetryableStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryNetworkErrors: true,
+		RespectRetryAfter:  true,
+	}
+}
+
+// Gets the total number of attempts allowed by the policy
+func (policy RetryPolicy) attempts() int {
+	if policy.MaxAttempts < 1 {
+		return 1
+	}
+	return policy.MaxAttempts
+}
+
+// Checks whether a response with the given status code should be retried
+func (policy RetryPolicy) retryableStatus(statusCode int) bool {
+	for _, code := range policy.RetryableStatus {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks whether a request that failed without a complete response should be retried. Errors caused by the
+// caller's context are never retried, and neither are responses that were received but could not be decoded
+// since they would fail the same way again.
+func (policy RetryPolicy) retryableError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrResponseTooLarge), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return false
+	}
+	return policy.RetryNetworkErrors
+}
+
+// Computes the delay before the given retry (1 for the first retry). The random function returns a value
+// in [0, n) and is only used when jitter is enabled.
+func (policy RetryPolicy) backoff(retry int, random func(n int64) int64) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < retry && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	if policy.Jitter && delay > 0 {
+		delay = time.Duration(random(int64(delay) + 1))
+	}
+	return delay
+}
+
+// Parses a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// Source of the current time and of delays, replaceable in tests so retries happen without real sleeps
+type Clock interface {
+	Now() time.Time
+	// Sleeps for the given duration or until ctx is done, in which case the context's error is returned
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// Clock backed by the system time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	return sleepContext(ctx, d)
+}
+
+// Random source used for jitter when none is injected
+func defaultRandom(n int64) int64 {
+	return rand.Int63n(n)
+}
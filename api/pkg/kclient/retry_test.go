@@ -0,0 +1,257 @@
+package kclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Clock that records requested sleeps instead of sleeping
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.sleeps = append(clock.sleeps, d)
+	clock.now = clock.now.Add(d)
+	return ctx.Err()
+}
+
+// Serves a token and then the given statuses for API requests in order, repeating the last one
+func newStatusServer(t *testing.T, statuses []int, header http.Header) (*httptest.Server, *int) {
+	var mu sync.Mutex
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/connect/oauth2/token" {
+			w.Write([]byte(`{"access_token":"abc","expires_in":1800,"token_type":"bearer"}`))
+			return
+		}
+
+		mu.Lock()
+		status := statuses[len(statuses)-1]
+		if calls < len(statuses) {
+			status = statuses[calls]
+		}
+		calls++
+		mu.Unlock()
+
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"data":[],"meta":{"pagination":{"total":0,"start":0,"limit":1}}}`))
+		} else {
+			w.Write([]byte(`{"errors":{"timestamp":1654041600000,"code":"Test","reason":"test failure"}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newRetryTestClient(t *testing.T, baseUrl string, policy RetryPolicy) (*KClient, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)}
+	client, err := New(baseUrl, "id", "secret", "FRED", WithRetryPolicy(policy), WithClock(clock))
+	if err != nil {
+		t.Fatalf("error during client setup, %v", err)
+	}
+	return client, clock
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	testCases := []struct {
+		retry    int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		if actual := policy.backoff(tc.retry, defaultRandom); actual != tc.expected {
+			t.Errorf("retry %d expected delay %v but got %v", tc.retry, tc.expected, actual)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second, Jitter: true}
+
+	// Full jitter picks anything from zero up to the computed backoff
+	if actual := policy.backoff(3, func(n int64) int64 { return n - 1 }); actual != 4*time.Second {
+		t.Errorf("expected upper bound of 4s but got %v", actual)
+	}
+	if actual := policy.backoff(3, func(n int64) int64 { return 0 }); actual != 0 {
+		t.Errorf("expected lower bound of 0 but got %v", actual)
+	}
+	for i := 0; i < 100; i++ {
+		if actual := policy.backoff(10, defaultRandom); actual < 0 || actual > 10*time.Second {
+			t.Fatalf("expected delay within [0, 10s] but got %v", actual)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "7", 7 * time.Second, true},
+		{"negative seconds", "-1", 0, false},
+		{"http date", "Wed, 01 Jun 2022 12:00:30 GMT", 30 * time.Second, true},
+		{"http date in the past", "Wed, 01 Jun 2022 11:00:00 GMT", 0, true},
+		{"garbage", "soon", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := parseRetryAfter(tc.value, now)
+			if ok != tc.ok || actual != tc.expected {
+				t.Errorf("expected (%v, %t) but got (%v, %t)", tc.expected, tc.ok, actual, ok)
+			}
+		})
+	}
+}
+
+func TestDoRetriesTransientStatus(t *testing.T) {
+	server, calls := newStatusServer(t, []int{503, 500, 200}, nil)
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, RetryableStatus: []int{500, 503}}
+	client, clock := newRetryTestClient(t, server.URL, policy)
+
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", Limit: 1}); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+
+	if *calls != 3 {
+		t.Errorf("expected 3 calls but got %d", *calls)
+	}
+	if len(clock.sleeps) != 2 || clock.sleeps[0] != time.Second || clock.sleeps[1] != 2*time.Second {
+		t.Errorf("expected sleeps [1s 2s] but got %v", clock.sleeps)
+	}
+}
+
+func TestDoStopsAfterMaxAttempts(t *testing.T) {
+	server, calls := newStatusServer(t, []int{500}, nil)
+	policy := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: time.Minute, RetryableStatus: []int{500}}
+	client, _ := newRetryTestClient(t, server.URL, policy)
+
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", Limit: 1}); err == nil {
+		t.Fatal("expected err but got none")
+	}
+	if *calls != 4 {
+		t.Errorf("expected 4 calls but got %d", *calls)
+	}
+}
+
+func TestDoDoesNotRetryClientErrors(t *testing.T) {
+	server, calls := newStatusServer(t, []int{400}, nil)
+	client, clock := newRetryTestClient(t, server.URL, DefaultRetryPolicy())
+
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", Limit: 1}); err == nil {
+		t.Fatal("expected err but got none")
+	}
+	if *calls != 1 || len(clock.sleeps) != 0 {
+		t.Errorf("expected a single call without sleeping but got %d calls and sleeps %v", *calls, clock.sleeps)
+	}
+}
+
+func TestDoHonorsRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"3"}}
+	server, calls := newStatusServer(t, []int{429, 200}, header)
+	client, clock := newRetryTestClient(t, server.URL, DefaultRetryPolicy())
+
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", Limit: 1}); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("expected 2 calls but got %d", *calls)
+	}
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 3*time.Second {
+		t.Errorf("expected sleeps [3s] but got %v", clock.sleeps)
+	}
+}
+
+func TestDoGivesUpOnLongRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"3600"}}
+	server, calls := newStatusServer(t, []int{429, 200}, header)
+	client, _ := newRetryTestClient(t, server.URL, DefaultRetryPolicy())
+
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", Limit: 1}); err == nil {
+		t.Fatal("expected err but got none")
+	}
+	if *calls != 1 {
+		t.Errorf("expected 1 call but got %d", *calls)
+	}
+}
+
+func TestDoRetriesNetworkErrors(t *testing.T) {
+	server, _ := newStatusServer(t, []int{200}, nil)
+	url := server.URL
+	server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, RetryNetworkErrors: true}
+	client, clock := newRetryTestClient(t, url, policy)
+	if err := client.GetAuthToken(context.Background()); err == nil {
+		t.Fatal("expected err but got none")
+	}
+	if len(clock.sleeps) != 2 {
+		t.Errorf("expected 2 sleeps but got %v", clock.sleeps)
+	}
+
+	policy.RetryNetworkErrors = false
+	client, clock = newRetryTestClient(t, url, policy)
+	if err := client.GetAuthToken(context.Background()); err == nil {
+		t.Fatal("expected err but got none")
+	}
+	if len(clock.sleeps) != 0 {
+		t.Errorf("expected no sleeps but got %v", clock.sleeps)
+	}
+}
+
+func TestDoReauthorizesOnce(t *testing.T) {
+	var mu sync.Mutex
+	var tokens, calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/connect/oauth2/token" {
+			tokens++
+			w.Write([]byte(`{"access_token":"abc","expires_in":1800,"token_type":"bearer"}`))
+			return
+		}
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_token","error_description":"revoked"}`))
+	}))
+	defer server.Close()
+
+	client, _ := newRetryTestClient(t, server.URL, DefaultRetryPolicy())
+	if _, err := client.GetProducts(context.Background(), ProductQuery{Term: "milk", Limit: 1}); err == nil {
+		t.Fatal("expected err but got none")
+	}
+	if tokens != 2 || calls != 2 {
+		t.Errorf("expected 2 token requests and 2 calls but got %d and %d", tokens, calls)
+	}
+}
@@ -0,0 +1,120 @@
+package kclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How long before the actual expiry a token is considered due for renewal
+const tokenRefreshMargin = time.Minute
+
+// Fetches a new OAuth2 token and reports how long it remains valid
+type tokenFetcher func(ctx context.Context) (token string, expiresIn time.Duration, err error)
+
+// A refresh of the token that is currently in progress, shared by every caller waiting on it
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// Caches an OAuth2 token and renews it before it expires. A single tokenSource is safe for
+// concurrent use and never runs more than one refresh at a time.
+type tokenSource struct {
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	inflight *tokenRefresh
+	fetch    tokenFetcher
+	now      func() time.Time
+}
+
+// Creates a new tokenSource that obtains tokens using the given fetcher
+func newTokenSource(fetch tokenFetcher) *tokenSource {
+	return &tokenSource{
+		fetch: fetch,
+		now:   time.Now,
+	}
+}
+
+// Gets a valid token. A cached token is returned when it is not close to expiring. A token within the
+// refresh margin is still returned but a background refresh is started so later callers get a fresh one.
+// Callers only wait when there is no usable token, and stop waiting when ctx is done.
+func (ts *tokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	now := ts.now()
+	if ts.token != "" && now.Before(ts.expiry) {
+		token := ts.token
+		if !now.Before(ts.expiry.Add(-tokenRefreshMargin)) {
+			ts.startRefresh()
+		}
+		ts.mu.Unlock()
+		return token, nil
+	}
+
+	refresh := ts.startRefresh()
+	ts.mu.Unlock()
+
+	return refresh.wait(ctx)
+}
+
+// Forces a new token to be fetched and waits for it
+func (ts *tokenSource) Refresh(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	ts.token = ""
+	refresh := ts.startRefresh()
+	ts.mu.Unlock()
+
+	return refresh.wait(ctx)
+}
+
+// Discards the given token if it is still the cached one, e.g. after the API rejected it with a 401.
+// The next call to Token will fetch a new one.
+func (ts *tokenSource) Invalidate(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token == token {
+		ts.token = ""
+		ts.expiry = time.Time{}
+	}
+}
+
+// Waits for the refresh to finish or for ctx to be done, whichever comes first
+func (refresh *tokenRefresh) wait(ctx context.Context) (string, error) {
+	select {
+	case <-refresh.done:
+		return refresh.token, refresh.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Starts a refresh unless one is already running and returns it. The refresh is shared by every waiting
+// caller, so it is not tied to any one caller's context. Must be called with ts.mu held.
+func (ts *tokenSource) startRefresh() *tokenRefresh {
+	if ts.inflight != nil {
+		return ts.inflight
+	}
+
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	ts.inflight = refresh
+
+	go func() {
+		token, expiresIn, err := ts.fetch(context.Background())
+
+		ts.mu.Lock()
+		if err == nil {
+			ts.token = token
+			ts.expiry = ts.now().Add(expiresIn)
+		}
+		ts.inflight = nil
+		ts.mu.Unlock()
+
+		refresh.token = token
+		refresh.err = err
+		close(refresh.done)
+	}()
+
+	return refresh
+}
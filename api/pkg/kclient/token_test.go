@@ -0,0 +1,170 @@
+package kclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Creates a tokenSource with a controllable clock whose fetcher hands out numbered tokens
+func newTestTokenSource(expiresIn time.Duration) (*tokenSource, *int32, *time.Time) {
+	var fetches int32
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	ts := newTokenSource(func(ctx context.Context) (string, time.Duration, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		return fmt.Sprintf("token-%d", n), expiresIn, nil
+	})
+	ts.now = func() time.Time { return now }
+	return ts, &fetches, &now
+}
+
+// Waits for any background refresh to complete
+func waitForRefresh(ts *tokenSource) {
+	ts.mu.Lock()
+	refresh := ts.inflight
+	ts.mu.Unlock()
+	if refresh != nil {
+		<-refresh.done
+	}
+}
+
+func TestTokenSourceCachesToken(t *testing.T) {
+	ts, fetches, _ := newTestTokenSource(30 * time.Minute)
+
+	for i := 0; i < 3; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatalf("expected success but got error, %v", err)
+		} else if token != "token-1" {
+			t.Fatalf("expected token-1 but got %s", token)
+		}
+	}
+
+	if *fetches != 1 {
+		t.Errorf("expected 1 fetch but got %d", *fetches)
+	}
+}
+
+func TestTokenSourceDeduplicatesConcurrentRefresh(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	ts := newTokenSource(func(ctx context.Context) (string, time.Duration, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "shared", 30 * time.Minute, nil
+	})
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = ts.Token(context.Background())
+		}(i)
+	}
+
+	// Give the callers a chance to queue up on the refresh before letting it finish
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Errorf("expected 1 fetch but got %d", fetches)
+	}
+	for i, token := range tokens {
+		if token != "shared" {
+			t.Errorf("caller %d expected token 'shared' but got '%s'", i, token)
+		}
+	}
+}
+
+func TestTokenSourceRefreshesBeforeExpiry(t *testing.T) {
+	ts, fetches, now := newTestTokenSource(30 * time.Minute)
+
+	if _, err := ts.Token(context.Background()); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+
+	// Inside the refresh margin the cached token is still served while a new one is fetched
+	*now = now.Add(30*time.Minute - tokenRefreshMargin/2)
+	token, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if token != "token-1" {
+		t.Fatalf("expected cached token-1 but got %s", token)
+	}
+
+	waitForRefresh(ts)
+	if *fetches != 2 {
+		t.Fatalf("expected a background refresh but got %d fetches", *fetches)
+	}
+
+	token, _ = ts.Token(context.Background())
+	if token != "token-2" {
+		t.Errorf("expected refreshed token-2 but got %s", token)
+	}
+}
+
+func TestTokenSourceExpiredToken(t *testing.T) {
+	ts, fetches, now := newTestTokenSource(30 * time.Minute)
+
+	_, _ = ts.Token(context.Background())
+	*now = now.Add(31 * time.Minute)
+
+	token, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if token != "token-2" {
+		t.Errorf("expected token-2 but got %s", token)
+	} else if *fetches != 2 {
+		t.Errorf("expected 2 fetches but got %d", *fetches)
+	}
+}
+
+func TestTokenSourceInvalidate(t *testing.T) {
+	ts, _, _ := newTestTokenSource(30 * time.Minute)
+
+	first, _ := ts.Token(context.Background())
+
+	// Invalidating a token that is no longer cached is a no-op
+	ts.Invalidate("stale")
+	if token, _ := ts.Token(context.Background()); token != first {
+		t.Fatalf("expected %s but got %s", first, token)
+	}
+
+	ts.Invalidate(first)
+	if token, _ := ts.Token(context.Background()); token == first {
+		t.Errorf("expected a new token after invalidation but got %s", token)
+	}
+}
+
+func TestTokenSourceWaitCancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	ts := newTokenSource(func(ctx context.Context) (string, time.Duration, error) {
+		<-release
+		return "late", 30 * time.Minute, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := ts.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded but got %v", err)
+	}
+}
+
+func TestTokenSourceFetchError(t *testing.T) {
+	ts := newTokenSource(func(ctx context.Context) (string, time.Duration, error) {
+		return "", 0, errors.New("bad credentials")
+	})
+
+	if _, err := ts.Token(context.Background()); err == nil {
+		t.Error("expected error but was none")
+	}
+}
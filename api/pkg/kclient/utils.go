@@ -1,8 +1,12 @@
 package kclient
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Counts the number of digits in a string
@@ -14,3 +18,27 @@ func countDigits(numberString string) (digits int) {
 	}
 	return digits
 }
+
+// Builds the key identifying a GroceryClient call from the method name and every argument, so that queries
+// differing in any filter get different keys. Used to share identical calls and to cache their responses.
+func RequestKey(method string, args ...interface{}) string {
+	key, err := json.Marshal(args)
+	if err != nil {
+		// The queries are plain structs so this cannot happen, but fall back to a printed form just in case
+		return fmt.Sprintf("%s:%v", method, args)
+	}
+	return fmt.Sprintf("%s:%s", method, key)
+}
+
+// Sleeps for the given duration or until ctx is done, in which case the context's error is returned
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
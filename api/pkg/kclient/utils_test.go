@@ -1,7 +1,11 @@
 package kclient
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCountDigits(t *testing.T) {
@@ -25,3 +29,45 @@ func TestCountDigits(t *testing.T) {
 		})
 	}
 }
+
+func TestSleepContext(t *testing.T) {
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled but got %v", err)
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected sleep to be interrupted but it took %v", elapsed)
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		args     []interface{}
+		expected string
+	}{
+		{"no arguments", "GetChains", nil, "GetChains:null"},
+		{"several arguments", "GetProduct", []interface{}{"0001111041700", "70100393"}, `GetProduct:["0001111041700","70100393"]`},
+		{"query", "GetProducts", []interface{}{ProductQuery{Term: "milk"}}, `GetProducts:[{"Term":"milk"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if key := RequestKey(tc.method, tc.args...); !strings.HasPrefix(key, tc.expected) {
+				t.Errorf("expected key '%s' but got '%s'", tc.expected, key)
+			}
+		})
+	}
+
+	// Queries differing in any filter get different keys
+	if RequestKey("GetProducts", ProductQuery{Term: "milk"}) == RequestKey("GetProducts", ProductQuery{Term: "milk", Brand: "Kroger"}) {
+		t.Errorf("expected different keys for different queries")
+	}
+}
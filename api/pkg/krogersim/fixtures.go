@@ -0,0 +1,110 @@
+package krogersim
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+//go:embed fixtures/*.json
+var embeddedFixtures embed.FS
+
+// A point given by latitude and longitude in decimal degrees
+type Coordinates struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// A product as stored in products.json. Store specific details such as price, stock level and aisle
+// locations are kept per location ID and merged into the product when a request is for that store.
+type ProductFixture struct {
+	models.Product
+	// JSON objects keyed by location ID holding the product fields that differ for the store, e.g.
+	// "aisleLocations" and "items"
+	Stores map[string]json.RawMessage `json:"stores"`
+}
+
+// The data the simulator answers requests from
+type Fixtures struct {
+	Locations   []models.Location
+	Products    []ProductFixture
+	Chains      []models.Chain
+	Departments []models.Department
+	// Center points of the zip codes that locations can be searched near
+	ZipCodes map[string]Coordinates
+}
+
+// Gets the fixtures compiled into the package, a handful of Portland area stores and grocery staples
+func DefaultFixtures() *Fixtures {
+	sub, err := fs.Sub(embeddedFixtures, "fixtures")
+	if err != nil {
+		panic(err)
+	}
+
+	fixtures, err := LoadFixtures(sub)
+	if err != nil {
+		panic(err)
+	}
+	return fixtures
+}
+
+// Loads fixtures from a directory on disk
+func LoadFixtureDir(dir string) (*Fixtures, error) {
+	return LoadFixtures(os.DirFS(dir))
+}
+
+// Loads fixtures from the locations.json, products.json, chains.json, departments.json and zipcodes.json files
+// in a file system
+func LoadFixtures(fsys fs.FS) (*Fixtures, error) {
+	var fixtures Fixtures
+	files := []struct {
+		name string
+		dest interface{}
+	}{
+		{"locations.json", &fixtures.Locations},
+		{"products.json", &fixtures.Products},
+		{"chains.json", &fixtures.Chains},
+		{"departments.json", &fixtures.Departments},
+		{"zipcodes.json", &fixtures.ZipCodes},
+	}
+
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture file: %v", err)
+		}
+		if err := json.Unmarshal(data, file.dest); err != nil {
+			return nil, fmt.Errorf("failed to deserialize fixture file '%s': %v", file.name, err)
+		}
+	}
+
+	return &fixtures, nil
+}
+
+// Gets the product as it appears for the given store. Without a location ID, or for a store with no details,
+// the product has no pricing, stock levels or aisle locations.
+func (fixture ProductFixture) forLocation(locationId string) (models.Product, error) {
+	product := fixture.Product
+	details, ok := fixture.Stores[locationId]
+	if locationId == "" || !ok {
+		return product, nil
+	}
+
+	// Round trip the product so unmarshalling the store details does not modify the shared fixture
+	data, err := json.Marshal(product)
+	if err != nil {
+		return product, err
+	}
+	var merged models.Product
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return product, err
+	}
+	if err := json.Unmarshal(details, &merged); err != nil {
+		return product, fmt.Errorf("invalid store details for product '%s': %v", fixture.ProductId, err)
+	}
+	return merged, nil
+}
@@ -0,0 +1,331 @@
+package krogersim
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// Searches locations near a zip code or coordinates, or by ID
+func (sim *Server) locations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, ok := intParam(w, query.Get("filter.limit"), "filter.limit", 10, 1, 200)
+	if !ok {
+		return
+	}
+	radius, ok := intParam(w, query.Get("filter.radiusInMiles"), "filter.radiusInMiles", 10, 1, 100)
+	if !ok {
+		return
+	}
+
+	// Work out the point to search near
+	var origin *Coordinates
+	zipCode := query.Get("filter.zipCode.near")
+	latLong := query.Get("filter.latLong.near")
+	lat, lon := query.Get("filter.lat.near"), query.Get("filter.lon.near")
+	switch {
+	case zipCode != "":
+		if len(zipCode) != 5 || strings.Trim(zipCode, "0123456789") != "" {
+			writeApiError(w, http.StatusBadRequest, "LOCATION-2011-400", "filter.zipCode.near must be a 5 digit zip code")
+			return
+		}
+		if center, ok := sim.fixtures.ZipCodes[zipCode]; ok {
+			origin = &center
+		} else {
+			// Unknown zip codes have no stores nearby
+			writeLocations(w, nil, 0)
+			return
+		}
+	case latLong != "":
+		parts := strings.Split(latLong, ",")
+		if len(parts) != 2 {
+			writeApiError(w, http.StatusBadRequest, "LOCATION-2012-400", "filter.latLong.near must be a latitude and longitude separated by a comma")
+			return
+		}
+		lat, lon = parts[0], parts[1]
+		fallthrough
+	case lat != "" || lon != "":
+		latNum, latErr := strconv.ParseFloat(lat, 64)
+		lonNum, lonErr := strconv.ParseFloat(lon, 64)
+		if latErr != nil || lonErr != nil {
+			writeApiError(w, http.StatusBadRequest, "LOCATION-2013-400", "filter.lat.near and filter.lon.near must both be numbers")
+			return
+		}
+		origin = &Coordinates{Lat: latNum, Lon: lonNum}
+	}
+
+	locationIds := listParam(query.Get("filter.locationId"))
+	if origin == nil && len(locationIds) == 0 {
+		writeApiError(w, http.StatusBadRequest, "LOCATION-2010-400", "A zip code, latitude and longitude, or location ID filter is required")
+		return
+	}
+
+	chain := query.Get("filter.chain")
+	departmentIds := listParam(query.Get("filter.department"))
+
+	type match struct {
+		location models.Location
+		distance float64
+	}
+	var matches []match
+	for _, location := range sim.fixtures.Locations {
+		if chain != "" && !strings.EqualFold(chain, location.Chain) {
+			continue
+		} else if !location.MatchesIds(locationIds) {
+			continue
+		} else if !location.HasDepartments(departmentIds) {
+			continue
+		}
+
+		var distance float64
+		if origin != nil {
+			distance = distanceInMiles(*origin, Coordinates{Lat: float64(location.Geolocation.Latitude), Lon: float64(location.Geolocation.Longitude)})
+			if distance > float64(radius) {
+				continue
+			}
+		}
+		matches = append(matches, match{location, distance})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+
+	var locations []models.Location
+	for _, m := range matches {
+		locations = append(locations, m.location)
+	}
+	writeLocations(w, locations, limit)
+}
+
+// Writes a page of locations starting at the first one
+func writeLocations(w http.ResponseWriter, locations []models.Location, limit int) {
+	var resp models.LocationsResponse
+	resp.Data = []models.Location{}
+	resp.Meta.Pagination.Total = len(locations)
+	resp.Meta.Pagination.Limit = limit
+	if limit > 0 && len(locations) > limit {
+		locations = locations[:limit]
+	}
+	resp.Data = append(resp.Data, locations...)
+	writeJson(w, http.StatusOK, resp)
+}
+
+// Gets a single location
+func (sim *Server) location(w http.ResponseWriter, r *http.Request) {
+	locationId := chi.URLParam(r, "locationId")
+	if len(locationId) != 8 {
+		writeApiError(w, http.StatusBadRequest, "LOCATION-2001-400", "locationId must have a length of 8 characters")
+		return
+	}
+
+	for _, location := range sim.fixtures.Locations {
+		if location.LocationId == locationId {
+			var resp models.LocationResponse
+			resp.Data = location
+			writeJson(w, http.StatusOK, resp)
+			return
+		}
+	}
+	writeApiError(w, http.StatusNotFound, "LOCATION-4040-404", fmt.Sprintf("Location '%s' not found", locationId))
+}
+
+// Searches products by term, brand or ID
+func (sim *Server) products(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, ok := intParam(w, query.Get("filter.limit"), "filter.limit", 10, 1, 50)
+	if !ok {
+		return
+	}
+	start, ok := intParam(w, query.Get("filter.start"), "filter.start", 0, 0, 1000)
+	if !ok {
+		return
+	}
+
+	term := query.Get("filter.term")
+	brand := query.Get("filter.brand")
+	productIds := listParam(query.Get("filter.productId"))
+	locationId := query.Get("filter.locationId")
+	fulfillment := query.Get("filter.fulfillment")
+
+	if term == "" && brand == "" && len(productIds) == 0 {
+		writeApiError(w, http.StatusBadRequest, "PRODUCT-2010-400", "A term, brand or productId filter is required")
+		return
+	} else if locationId != "" && len(locationId) != 8 {
+		writeApiError(w, http.StatusBadRequest, "PRODUCT-2011-400", "Field 'locationId' must have a length of 8 characters")
+		return
+	} else if fulfillment != "" && locationId == "" {
+		writeApiError(w, http.StatusBadRequest, "PRODUCT-2012-400", "Field 'fulfillment' requires a 'locationId'")
+		return
+	} else if fulfillment != "" && !validFulfillment(fulfillment) {
+		writeApiError(w, http.StatusBadRequest, "PRODUCT-2013-400", "Field 'fulfillment' must be one of ais, csp, dth or sth")
+		return
+	}
+
+	terms := strings.Fields(strings.ToLower(term))
+	var matches []models.Product
+	for _, fixture := range sim.fixtures.Products {
+		if !fixture.MatchesIds(productIds) {
+			continue
+		} else if brand != "" && !strings.EqualFold(brand, fixture.Brand) {
+			continue
+		} else if !matchesTerms(fixture.Product, terms) {
+			continue
+		}
+
+		product, err := fixture.forLocation(locationId)
+		if err != nil {
+			writeApiError(w, http.StatusInternalServerError, "SIM-5000-500", err.Error())
+			return
+		}
+
+		// Products the store does not carry cannot be obtained from it at all
+		if _, carried := fixture.Stores[locationId]; fulfillment != "" && (!carried || !product.HasFulfillment(fulfillment)) {
+			continue
+		}
+		matches = append(matches, product)
+	}
+
+	var resp models.ProductsResponse
+	resp.Data = []models.Product{}
+	resp.Meta.Pagination.Total = len(matches)
+	resp.Meta.Pagination.Start = start
+	resp.Meta.Pagination.Limit = limit
+	if start < len(matches) {
+		end := start + limit
+		if end > len(matches) {
+			end = len(matches)
+		}
+		resp.Data = append(resp.Data, matches[start:end]...)
+	}
+	writeJson(w, http.StatusOK, resp)
+}
+
+// Gets a single product
+func (sim *Server) product(w http.ResponseWriter, r *http.Request) {
+	productId := chi.URLParam(r, "productId")
+	locationId := r.URL.Query().Get("filter.locationId")
+	if locationId != "" && len(locationId) != 8 {
+		writeApiError(w, http.StatusBadRequest, "PRODUCT-2011-400", "Field 'locationId' must have a length of 8 characters")
+		return
+	}
+
+	for _, fixture := range sim.fixtures.Products {
+		if fixture.ProductId != productId {
+			continue
+		}
+
+		product, err := fixture.forLocation(locationId)
+		if err != nil {
+			writeApiError(w, http.StatusInternalServerError, "SIM-5000-500", err.Error())
+			return
+		}
+
+		var resp models.ProductResponse
+		resp.Data = product
+		writeJson(w, http.StatusOK, resp)
+		return
+	}
+	writeApiError(w, http.StatusNotFound, "PRODUCT-4040-404", fmt.Sprintf("Product '%s' not found", productId))
+}
+
+// Lists chains
+func (sim *Server) chains(w http.ResponseWriter, r *http.Request) {
+	var resp models.ChainsResponse
+	resp.Data = append([]models.Chain{}, sim.fixtures.Chains...)
+	writeJson(w, http.StatusOK, resp)
+}
+
+// Gets a single chain
+func (sim *Server) chain(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	for _, chain := range sim.fixtures.Chains {
+		if strings.EqualFold(chain.Name, name) {
+			var resp models.ChainResponse
+			resp.Data = chain
+			writeJson(w, http.StatusOK, resp)
+			return
+		}
+	}
+	writeApiError(w, http.StatusNotFound, "CHAIN-4040-404", fmt.Sprintf("Chain '%s' not found", name))
+}
+
+// Lists departments
+func (sim *Server) departments(w http.ResponseWriter, r *http.Request) {
+	var resp models.DepartmentsResponse
+	resp.Data = append([]models.Department{}, sim.fixtures.Departments...)
+	writeJson(w, http.StatusOK, resp)
+}
+
+// Gets a single department
+func (sim *Server) department(w http.ResponseWriter, r *http.Request) {
+	departmentId := chi.URLParam(r, "departmentId")
+	for _, department := range sim.fixtures.Departments {
+		if department.DepartmentID == departmentId {
+			var resp models.DepartmentResponse
+			resp.Data = department
+			writeJson(w, http.StatusOK, resp)
+			return
+		}
+	}
+	writeApiError(w, http.StatusNotFound, "DEPARTMENT-4040-404", fmt.Sprintf("Department '%s' not found", departmentId))
+}
+
+// Parses an optional integer query parameter, writing a validation error and returning false when it is invalid
+func intParam(w http.ResponseWriter, value string, name string, defaultValue int, min int, max int) (int, bool) {
+	if value == "" {
+		return defaultValue, true
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min || number > max {
+		writeApiError(w, http.StatusBadRequest, "SIM-2000-400", fmt.Sprintf("Field '%s' must be a number from %d to %d", name, min, max))
+		return 0, false
+	}
+	return number, true
+}
+
+// Splits a comma separated query parameter
+func listParam(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// Checks whether the fulfillment type is one of Kroger's codes
+func validFulfillment(fulfillment string) bool {
+	switch fulfillment {
+	case "ais", "csp", "dth", "sth":
+		return true
+	}
+	return false
+}
+
+// Checks whether every search term appears in the product's description or brand
+func matchesTerms(product models.Product, terms []string) bool {
+	text := strings.ToLower(product.Description + " " + product.Brand)
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// Computes the great-circle distance between two points
+func distanceInMiles(a Coordinates, b Coordinates) float64 {
+	const earthRadiusInMiles = 3958.8
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	dLat := toRadians(b.Lat - a.Lat)
+	dLon := toRadians(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRadians(a.Lat))*math.Cos(toRadians(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusInMiles * math.Asin(math.Sqrt(h))
+}
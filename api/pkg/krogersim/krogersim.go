@@ -0,0 +1,260 @@
+// Package krogersim is a stand-in for the Kroger API that serves locations, products, chains and
+// departments from fixture files. It issues OAuth2 tokens, applies the same filters, pagination and validation
+// rules as the real API, responds with Kroger's error body shapes and can inject faults, so kclient and the
+// API server can be run and tested without credentials or a network connection.
+package krogersim
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+)
+
+// A failure the simulator responds with instead of handling a request
+type Fault struct {
+	// Only requests whose path starts with this are affected. Empty matches every request.
+	Path string `json:"path"`
+	// Status code to respond with, e.g. 500 or 429
+	Status int `json:"status"`
+	// Number of requests to fail before the fault is removed. Zero fails every request.
+	Count int `json:"count"`
+	// Optional Retry-After header value
+	RetryAfter string `json:"retryAfter"`
+	// Time to wait before responding, e.g. to trigger client timeouts
+	Delay time.Duration `json:"-"`
+}
+
+// Simulated Kroger API server
+type Server struct {
+	clientId      string
+	clientSecret  string
+	tokenLifetime time.Duration
+	fixtures      *Fixtures
+	router        http.Handler
+
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+	faults   []*Fault
+	requests map[string]int
+}
+
+// Creates a simulator that answers from the given fixtures and accepts the given client credentials
+func New(fixtures *Fixtures, clientId string, clientSecret string) *Server {
+	sim := &Server{
+		clientId:      clientId,
+		clientSecret:  clientSecret,
+		tokenLifetime: 30 * time.Minute,
+		fixtures:      fixtures,
+		tokens:        make(map[string]time.Time),
+		requests:      make(map[string]int),
+	}
+
+	r := chi.NewRouter()
+	r.Post("/connect/oauth2/token", sim.token)
+
+	r.Post("/_sim/faults", sim.addFault)
+	r.Delete("/_sim/faults", sim.clearFaults)
+
+	r.Group(func(r chi.Router) {
+		r.Use(sim.authorize)
+		r.Get("/locations", sim.locations)
+		r.Get("/locations/{locationId}", sim.location)
+		r.Get("/products", sim.products)
+		r.Get("/products/{productId}", sim.product)
+		r.Get("/chains", sim.chains)
+		r.Get("/chains/{name}", sim.chain)
+		r.Get("/departments", sim.departments)
+		r.Get("/departments/{departmentId}", sim.department)
+	})
+
+	sim.router = r
+	return sim
+}
+
+// Sets how long issued tokens are valid for
+func (sim *Server) SetTokenLifetime(lifetime time.Duration) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.tokenLifetime = lifetime
+}
+
+// Adds a fault that following requests will fail with
+func (sim *Server) InjectFault(fault Fault) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.faults = append(sim.faults, &fault)
+}
+
+// Removes every injected fault
+func (sim *Server) ClearFaults() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.faults = nil
+}
+
+// Revokes every issued token so that requests using them are rejected with a 401
+func (sim *Server) RevokeTokens() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.tokens = make(map[string]time.Time)
+}
+
+// Gets the number of requests received for a path, including ones that failed
+func (sim *Server) Requests(path string) int {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.requests[path]
+}
+
+func (sim *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sim.mu.Lock()
+	sim.requests[r.URL.Path]++
+	fault := sim.takeFault(r.URL.Path)
+	sim.mu.Unlock()
+
+	if fault != nil {
+		sim.respondWithFault(w, r, fault)
+		return
+	}
+
+	sim.router.ServeHTTP(w, r)
+}
+
+// Finds a fault matching the path and uses it up. Must be called with sim.mu held.
+func (sim *Server) takeFault(path string) *Fault {
+	if strings.HasPrefix(path, "/_sim/") {
+		return nil
+	}
+
+	for i, fault := range sim.faults {
+		if !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+
+		match := *fault
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				sim.faults = append(sim.faults[:i], sim.faults[i+1:]...)
+			}
+		}
+		return &match
+	}
+	return nil
+}
+
+// Writes the response for an injected fault
+func (sim *Server) respondWithFault(w http.ResponseWriter, r *http.Request, fault *Fault) {
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fault.RetryAfter != "" {
+		w.Header().Set("Retry-After", fault.RetryAfter)
+	}
+
+	if r.URL.Path == "/connect/oauth2/token" {
+		writeAuthError(w, fault.Status, "server_error", "injected fault")
+		return
+	}
+	writeApiError(w, fault.Status, "SIM-FAULT", "injected fault")
+}
+
+// Adds a fault posted as JSON
+func (sim *Server) addFault(w http.ResponseWriter, r *http.Request) {
+	var fault Fault
+	if err := json.NewDecoder(r.Body).Decode(&fault); err != nil || fault.Status == 0 {
+		writeApiError(w, http.StatusBadRequest, "SIM-4000-400", "body must be a fault with a status")
+		return
+	}
+
+	sim.InjectFault(fault)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Removes every injected fault
+func (sim *Server) clearFaults(w http.ResponseWriter, r *http.Request) {
+	sim.ClearFaults()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Issues a token to clients that present the configured credentials
+func (sim *Server) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != sim.clientId || secret != sim.clientSecret {
+		writeAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Only the client_credentials grant type is supported")
+		return
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		writeAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	token := hex.EncodeToString(buf)
+
+	sim.mu.Lock()
+	lifetime := sim.tokenLifetime
+	sim.tokens[token] = time.Now().Add(lifetime)
+	sim.mu.Unlock()
+
+	writeJson(w, http.StatusOK, models.AuthorizationResponse{
+		ExpiresIn:   int(lifetime / time.Second),
+		AccessToken: token,
+		TokenType:   "bearer",
+	})
+}
+
+// Rejects requests without a valid bearer token
+func (sim *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		sim.mu.Lock()
+		expiry, ok := sim.tokens[token]
+		sim.mu.Unlock()
+
+		if !ok || time.Now().After(expiry) {
+			writeAuthError(w, http.StatusUnauthorized, "invalid_token", "The access token is missing, invalid or expired")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Writes data as a JSON response
+func writeJson(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// Writes an error in the shape the Kroger API uses for request errors
+func writeApiError(w http.ResponseWriter, status int, code string, reason string) {
+	var errRes models.ApiErrorResponse
+	errRes.Errors.TimeStamp = int(time.Now().UnixMilli())
+	errRes.Errors.Code = code
+	errRes.Errors.Reason = reason
+	writeJson(w, status, errRes)
+}
+
+// Writes an error in the shape the Kroger API uses for authorization errors
+func writeAuthError(w http.ResponseWriter, status int, code string, description string) {
+	writeJson(w, status, models.AuthErrorResponse{Error: code, ErrorDescription: description})
+}
@@ -0,0 +1,229 @@
+package krogersim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jondysinger/grocery-data/api/pkg/models"
+	"github.com/jondysinger/grocery-data/api/pkg/money"
+)
+
+// Starts a simulator with the default fixtures and gets a token for it
+func newTestServer(t *testing.T) (*Server, *httptest.Server, string) {
+	sim := New(DefaultFixtures(), "id", "secret")
+	server := httptest.NewServer(sim)
+	t.Cleanup(server.Close)
+
+	req, _ := http.NewRequest("POST", server.URL+"/connect/oauth2/token", strings.NewReader("grant_type=client_credentials&scope=product.compact"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("id", "secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("token request failed, %v", err)
+	}
+	defer res.Body.Close()
+
+	var authRes models.AuthorizationResponse
+	if err := json.NewDecoder(res.Body).Decode(&authRes); err != nil || authRes.AccessToken == "" {
+		t.Fatalf("expected a token but got %v", err)
+	}
+	return sim, server, authRes.AccessToken
+}
+
+// Sends an authorized GET request and decodes the response into out
+func getJson(t *testing.T, server *httptest.Server, token string, path string, query url.Values, out interface{}) int {
+	target := server.URL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, _ := http.NewRequest("GET", target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed, %v", err)
+	}
+	defer res.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("failed to decode response, %v", err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestTokenRejectsBadCredentials(t *testing.T) {
+	_, server, _ := newTestServer(t)
+
+	req, _ := http.NewRequest("POST", server.URL+"/connect/oauth2/token", strings.NewReader("grant_type=client_credentials"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("id", "wrong")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed, %v", err)
+	}
+	defer res.Body.Close()
+
+	var errRes models.AuthErrorResponse
+	_ = json.NewDecoder(res.Body).Decode(&errRes)
+	if res.StatusCode != http.StatusUnauthorized || errRes.Error != "invalid_client" {
+		t.Errorf("expected a 401 invalid_client error but got %d %+v", res.StatusCode, errRes)
+	}
+}
+
+func TestRequiresBearerToken(t *testing.T) {
+	sim, server, token := newTestServer(t)
+
+	if status := getJson(t, server, "nope", "/chains", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for an unknown token but got %d", status)
+	}
+
+	sim.RevokeTokens()
+	if status := getJson(t, server, token, "/chains", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for a revoked token but got %d", status)
+	}
+}
+
+func TestLocationSearch(t *testing.T) {
+	_, server, token := newTestServer(t)
+	testCases := []struct {
+		name     string
+		query    url.Values
+		expected []string
+	}{
+		{"zip code nearest first", url.Values{"filter.zipCode.near": {"97224"}, "filter.chain": {"FRED"}}, []string{"70100150", "70100393", "70100116"}},
+		{"radius", url.Values{"filter.zipCode.near": {"97224"}, "filter.radiusInMiles": {"3"}}, []string{"70100150", "70100393"}},
+		{"department", url.Values{"filter.zipCode.near": {"97224"}, "filter.department": {"09,20"}}, []string{"70100393", "70100116"}},
+		{"lat long", url.Values{"filter.latLong.near": {"45.5346,-122.6368"}, "filter.radiusInMiles": {"1"}}, []string{"70100224"}},
+		{"lat and lon", url.Values{"filter.lat.near": {"45.4690"}, "filter.lon.near": {"-122.7110"}, "filter.chain": {"QFC"}}, []string{"70500822"}},
+		{"location ids", url.Values{"filter.locationId": {"70100224,70500822"}}, []string{"70500822", "70100224"}},
+		{"limit", url.Values{"filter.zipCode.near": {"97224"}, "filter.limit": {"1"}}, []string{"70100150"}},
+		{"unknown zip code", url.Values{"filter.zipCode.near": {"10001"}}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp models.LocationsResponse
+			if status := getJson(t, server, token, "/locations", tc.query, &resp); status != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d", status)
+			}
+
+			var actual []string
+			for _, location := range resp.Data {
+				actual = append(actual, location.LocationId)
+			}
+			if strings.Join(actual, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("expected locations %v but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestProductSearch(t *testing.T) {
+	_, server, token := newTestServer(t)
+
+	// Store details are only included for the requested store
+	var resp models.ProductsResponse
+	getJson(t, server, token, "/products", url.Values{"filter.term": {"milk"}, "filter.brand": {"Kroger"}, "filter.locationId": {"70100393"}}, &resp)
+	if len(resp.Data) != 2 || resp.Data[0].Items[0].Price.Regular != money.MustParse("3.49") || len(resp.Data[0].AisleLocations) != 1 {
+		t.Fatalf("expected 2 priced Kroger milks but got %+v", resp.Data)
+	}
+
+	getJson(t, server, token, "/products", url.Values{"filter.term": {"milk"}}, &resp)
+	if resp.Data[0].Items[0].Price.Regular != 0 || len(resp.Data[0].AisleLocations) != 0 {
+		t.Errorf("expected no store details without a location but got %+v", resp.Data[0])
+	}
+
+	// Out of stock items are not available for curbside pickup
+	getJson(t, server, token, "/products", url.Values{"filter.term": {"eggs"}, "filter.locationId": {"70100150"}, "filter.fulfillment": {"csp"}}, &resp)
+	if len(resp.Data) != 0 {
+		t.Errorf("expected no curbside eggs but got %d products", len(resp.Data))
+	}
+
+	// Pagination
+	getJson(t, server, token, "/products", url.Values{"filter.term": {"milk"}, "filter.start": {"3"}, "filter.limit": {"2"}}, &resp)
+	if resp.Meta.Pagination.Total != 5 || resp.Meta.Pagination.Start != 3 || len(resp.Data) != 2 {
+		t.Errorf("expected products 4 and 5 of 5 but got %+v with %d products", resp.Meta.Pagination, len(resp.Data))
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	_, server, token := newTestServer(t)
+	testCases := []struct {
+		name  string
+		path  string
+		query url.Values
+	}{
+		{"no location filter", "/locations", url.Values{}},
+		{"bad zip code", "/locations", url.Values{"filter.zipCode.near": {"1234"}}},
+		{"location limit too high", "/locations", url.Values{"filter.zipCode.near": {"97224"}, "filter.limit": {"201"}}},
+		{"no product filter", "/products", url.Values{"filter.locationId": {"70100393"}}},
+		{"short location id", "/products", url.Values{"filter.term": {"milk"}, "filter.locationId": {"12345"}}},
+		{"fulfillment without location", "/products", url.Values{"filter.term": {"milk"}, "filter.fulfillment": {"csp"}}},
+		{"product limit too high", "/products", url.Values{"filter.term": {"milk"}, "filter.limit": {"51"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errRes models.ApiErrorResponse
+			if status := getJson(t, server, token, tc.path, tc.query, &errRes); status != http.StatusBadRequest {
+				t.Fatalf("expected status 400 but got %d", status)
+			}
+			if errRes.Errors.Code == "" || errRes.Errors.Reason == "" {
+				t.Errorf("expected an error code and reason but got %+v", errRes)
+			}
+		})
+	}
+}
+
+func TestDetailsNotFound(t *testing.T) {
+	_, server, token := newTestServer(t)
+	for _, path := range []string{"/locations/99999999", "/products/0000000000000", "/chains/NOPE", "/departments/99"} {
+		if status := getJson(t, server, token, path, nil, nil); status != http.StatusNotFound {
+			t.Errorf("%s expected status 404 but got %d", path, status)
+		}
+	}
+}
+
+func TestInjectedFaults(t *testing.T) {
+	sim, server, token := newTestServer(t)
+	sim.InjectFault(Fault{Path: "/products", Status: http.StatusTooManyRequests, Count: 2, RetryAfter: "1"})
+
+	query := url.Values{"filter.term": {"milk"}}
+	for i := 0; i < 2; i++ {
+		if status := getJson(t, server, token, "/products", query, nil); status != http.StatusTooManyRequests {
+			t.Fatalf("request %d expected status 429 but got %d", i, status)
+		}
+	}
+	if status := getJson(t, server, token, "/products", query, nil); status != http.StatusOK {
+		t.Fatalf("expected the fault to be used up but got %d", status)
+	}
+	if status := getJson(t, server, token, "/locations/70100393", nil, nil); status != http.StatusOK {
+		t.Errorf("expected other paths to be unaffected but got %d", status)
+	}
+	if sim.Requests("/products") != 3 {
+		t.Errorf("expected 3 product requests but got %d", sim.Requests("/products"))
+	}
+
+	// Faults can also be added over HTTP when the simulator runs as a separate process
+	res, err := http.Post(server.URL+"/_sim/faults", "application/json", strings.NewReader(`{"path":"/chains","status":503}`))
+	if err != nil || res.StatusCode != http.StatusNoContent {
+		t.Fatalf("failed to add a fault over HTTP, %v", err)
+	}
+	res.Body.Close()
+	for i := 0; i < 3; i++ {
+		if status := getJson(t, server, token, "/chains", nil, nil); status != http.StatusServiceUnavailable {
+			t.Fatalf("expected status 503 but got %d", status)
+		}
+	}
+
+	sim.ClearFaults()
+	if status := getJson(t, server, token, "/chains", nil, nil); status != http.StatusOK {
+		t.Errorf("expected status 200 after clearing faults but got %d", status)
+	}
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Aisle number given to departments that are not in a numbered aisle, such as the deli or bakery, so they sort
+// after the numbered aisles
+const unnumberedAisle = 9999
+
+// Where a product is shelved in a store, as Kroger gives it. Every field is a string, and numbers may be blank.
+type AisleLocation struct {
+	BayNumber          string `json:"bayNumber"`
+	Description        string `json:"description"`
+	Number             string `json:"number"`
+	NumberOfFacings    string `json:"numberOfFacings"`
+	SequenceNumber     string `json:"sequenceNumber"`
+	Side               string `json:"side"`
+	ShelfNumber        string `json:"shelfNumber"`
+	ShelfPositionInBay string `json:"shelfPositionInBay"`
+}
+
+// An aisle location parsed into numbers, with directions a shopper can follow. Numbers Kroger leaves blank or
+// gives as something other than a number are zero.
+type Aisle struct {
+	// Aisle number, zero for a department outside the numbered aisles
+	Number      int    `json:"number"`
+	Description string `json:"description"`
+	// "left" or "right" when facing down the aisle, blank when unknown
+	Side string `json:"side,omitempty"`
+	Bay  int    `json:"bay"`
+	// Shelf counted from the bottom, starting at 1
+	Shelf int `json:"shelf"`
+	// Position along the shelf within the bay
+	Position int `json:"position"`
+	Facings  int `json:"facings"`
+	// Orders locations by aisle, bay, side, shelf and position, so sorting on it gives a walking order
+	SortKey string `json:"sortKey"`
+	// Directions such as "Aisle 12, left side, bay 4, 3rd shelf from bottom"
+	Directions string `json:"directions"`
+}
+
+// Parses the location into numbers and directions
+func (location AisleLocation) Parse() Aisle {
+	aisle := Aisle{
+		Number:      parseAisleNumber(location.Number),
+		Description: strings.TrimSpace(location.Description),
+		Bay:         parseAisleNumber(location.BayNumber),
+		Shelf:       parseAisleNumber(location.ShelfNumber),
+		Position:    parseAisleNumber(location.ShelfPositionInBay),
+		Facings:     parseAisleNumber(location.NumberOfFacings),
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(location.Side)) {
+	case "L", "LEFT":
+		aisle.Side = "left"
+	case "R", "RIGHT":
+		aisle.Side = "right"
+	}
+
+	aisle.SortKey = aisle.sortKey()
+	aisle.Directions = aisle.directions()
+	return aisle
+}
+
+// Parses each location, sorted into walking order
+func ParseAisles(locations []AisleLocation) []Aisle {
+	aisles := make([]Aisle, 0, len(locations))
+	for _, location := range locations {
+		aisles = append(aisles, location.Parse())
+	}
+	sort.SliceStable(aisles, func(i, j int) bool {
+		return aisles[i].SortKey < aisles[j].SortKey
+	})
+	return aisles
+}
+
+// Gets a key that sorts as the numbers do, e.g. "0012-0004-L-03-02"
+func (aisle Aisle) sortKey() string {
+	number := aisle.Number
+	if number == 0 {
+		number = unnumberedAisle
+	}
+	side := "-"
+	if aisle.Side != "" {
+		side = strings.ToUpper(aisle.Side[:1])
+	}
+	return fmt.Sprintf("%04d-%04d-%s-%02d-%02d", number, aisle.Bay, side, aisle.Shelf, aisle.Position)
+}
+
+// Gets the directions to the location, leaving out anything that is unknown
+func (aisle Aisle) directions() string {
+	var parts []string
+	if aisle.Number > 0 {
+		parts = append(parts, fmt.Sprintf("Aisle %d", aisle.Number))
+	} else if aisle.Description != "" {
+		parts = append(parts, titleCase(aisle.Description))
+	}
+	if aisle.Side != "" {
+		parts = append(parts, aisle.Side+" side")
+	}
+	if aisle.Bay > 0 {
+		parts = append(parts, fmt.Sprintf("bay %d", aisle.Bay))
+	}
+	if aisle.Shelf > 0 {
+		parts = append(parts, ordinal(aisle.Shelf)+" shelf from bottom")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Parses a positive whole number, giving zero when it is blank or not a number
+func parseAisleNumber(value string) int {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || number < 0 {
+		return 0
+	}
+	return number
+}
+
+// Gets a number as an ordinal such as "1st", "12th" or "23rd"
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// Capitalizes the first letter of each word, e.g. "FROZEN FOODS" to "Frozen Foods"
+func titleCase(value string) string {
+	words := strings.Fields(strings.ToLower(value))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAisleLocationParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		location AisleLocation
+		expected Aisle
+	}{
+		{
+			"numbered aisle",
+			AisleLocation{BayNumber: "4", Description: "DAIRY", Number: "12", NumberOfFacings: "3", Side: "L", ShelfNumber: "3", ShelfPositionInBay: "2"},
+			Aisle{Number: 12, Description: "DAIRY", Side: "left", Bay: 4, Shelf: 3, Position: 2, Facings: 3, SortKey: "0012-0004-L-03-02", Directions: "Aisle 12, left side, bay 4, 3rd shelf from bottom"},
+		},
+		{
+			"right side first shelf",
+			AisleLocation{BayNumber: "11", Number: "7", Side: "r", ShelfNumber: "1", ShelfPositionInBay: "1"},
+			Aisle{Number: 7, Side: "right", Bay: 11, Shelf: 1, Position: 1, SortKey: "0007-0011-R-01-01", Directions: "Aisle 7, right side, bay 11, 1st shelf from bottom"},
+		},
+		{
+			"department without an aisle number",
+			AisleLocation{Description: "FROZEN FOODS", Number: "", ShelfNumber: "2"},
+			Aisle{Description: "FROZEN FOODS", Shelf: 2, SortKey: "9999-0000---02-00", Directions: "Frozen Foods, 2nd shelf from bottom"},
+		},
+		{
+			"unparseable numbers",
+			AisleLocation{BayNumber: "A", Number: "12B", Side: "?", ShelfNumber: "-1"},
+			Aisle{SortKey: "9999-0000---00-00"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.location.Parse(); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %+v but got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseAislesWalkingOrder(t *testing.T) {
+	aisles := ParseAisles([]AisleLocation{
+		{Description: "DELI"},
+		{Number: "12", BayNumber: "4", Side: "R", ShelfNumber: "1"},
+		{Number: "3", BayNumber: "10", Side: "L", ShelfNumber: "5"},
+		{Number: "12", BayNumber: "4", Side: "L", ShelfNumber: "2"},
+		{Number: "12", BayNumber: "2", Side: "R", ShelfNumber: "4"},
+	})
+
+	var actual []string
+	for _, aisle := range aisles {
+		actual = append(actual, aisle.Directions)
+	}
+	expected := []string{
+		"Aisle 3, left side, bay 10, 5th shelf from bottom",
+		"Aisle 12, right side, bay 2, 4th shelf from bottom",
+		"Aisle 12, left side, bay 4, 2nd shelf from bottom",
+		"Aisle 12, right side, bay 4, 1st shelf from bottom",
+		"Deli",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	testCases := map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 102: "102nd", 111: "111th"}
+	for n, expected := range testCases {
+		if actual := ordinal(n); actual != expected {
+			t.Errorf("expected %s but got %s", expected, actual)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	// Stores are looked up by their IANA timezone, which needs the timezone database even where the system
+	// has none, such as in a minimal container
+	_ "time/tzdata"
+)
+
+// Longest stretch searched for a store's next opening or closing. A week covers every day's hours, and one
+// more day covers hours running past midnight into the day the search started on.
+const hoursSearchDays = 8
+
+// Matches the offset in a GmtOffset such as "(UTC-08:00) Pacific Time (US & Canada)"
+var gmtOffsetRegexp = regexp.MustCompile(`UTC([+-])(\d{1,2}):?(\d{2})?`)
+
+// Hours a store is open on one day of the week. Open and Close are local times like "06:00", and a Close at or
+// before Open means the store closes after midnight, e.g. "01:00" the next day.
+type DayHours struct {
+	Open   string `json:"open"`
+	Close  string `json:"close"`
+	Open24 bool   `json:"open24"`
+}
+
+// Weekly hours of a store in the store's timezone
+type StoreHours struct {
+	// IANA timezone name such as "America/Los_Angeles"
+	Timezone string `json:"timezone"`
+	// Offset description such as "(UTC-08:00) Pacific Time (US & Canada)", used when Timezone is unknown
+	GmtOffset string `json:"gmtOffset"`
+	// The store never closes
+	Open24    bool     `json:"open24"`
+	Monday    DayHours `json:"monday"`
+	Tuesday   DayHours `json:"tuesday"`
+	Wednesday DayHours `json:"wednesday"`
+	Thursday  DayHours `json:"thursday"`
+	Friday    DayHours `json:"friday"`
+	Saturday  DayHours `json:"saturday"`
+	Sunday    DayHours `json:"sunday"`
+}
+
+// Gets the hours for a day of the week
+func (hours StoreHours) Day(weekday time.Weekday) DayHours {
+	switch weekday {
+	case time.Monday:
+		return hours.Monday
+	case time.Tuesday:
+		return hours.Tuesday
+	case time.Wednesday:
+		return hours.Wednesday
+	case time.Thursday:
+		return hours.Thursday
+	case time.Friday:
+		return hours.Friday
+	case time.Saturday:
+		return hours.Saturday
+	}
+	return hours.Sunday
+}
+
+// Checks whether any hours are known. Kroger leaves them blank for some stores.
+func (hours StoreHours) Known() bool {
+	if hours.Open24 {
+		return true
+	}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if _, _, ok := hours.Day(weekday).span(); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the store's timezone from its name, then from its GMT offset, falling back to UTC when neither is
+// recognized
+func (hours StoreHours) Location() *time.Location {
+	if hours.Timezone != "" {
+		if loc, err := time.LoadLocation(hours.Timezone); err == nil {
+			return loc
+		}
+	}
+
+	if match := gmtOffsetRegexp.FindStringSubmatch(hours.GmtOffset); match != nil {
+		h, _ := strconv.Atoi(match[2])
+		m, _ := strconv.Atoi(match[3])
+		offset := h*60*60 + m*60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(fmt.Sprintf("UTC%s%s:%02d", match[1], match[2], m), offset)
+	}
+	return time.UTC
+}
+
+// Checks whether the store is open at the given time
+func (hours StoreHours) IsOpenAt(t time.Time) bool {
+	_, ok := hours.openingAround(t)
+	return ok
+}
+
+// Gets the next time the store opens, which is t itself when the store is open at t. Returns false when the
+// store has no opening hours.
+func (hours StoreHours) NextOpening(t time.Time) (time.Time, bool) {
+	if hours.IsOpenAt(t) {
+		return t, true
+	}
+
+	loc := hours.Location()
+	date := midnight(t.In(loc))
+	for i := 0; i < hoursSearchDays; i++ {
+		if start, _, ok := hours.interval(date.AddDate(0, 0, i)); ok && start.After(t) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Gets the time the store next closes when it is open at t. Returns false when the store is closed at t or
+// never closes.
+func (hours StoreHours) ClosesAt(t time.Time) (time.Time, bool) {
+	end, ok := hours.openingAround(t)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	// Follow on to the next day's hours while they start as soon as the current ones end, as they do for
+	// stores open all night
+	for i := 0; i < hoursSearchDays; i++ {
+		start, next, ok := hours.interval(midnight(end))
+		if !ok || !start.Equal(end) {
+			return end, true
+		}
+		end = next
+	}
+	return time.Time{}, false
+}
+
+// Gets how long until the store closes when it is open at t. Returns false when the store is closed at t or
+// never closes.
+func (hours StoreHours) ClosesIn(t time.Time) (time.Duration, bool) {
+	closesAt, ok := hours.ClosesAt(t)
+	if !ok {
+		return 0, false
+	}
+	return closesAt.Sub(t), true
+}
+
+// Gets the end of the opening hours that t falls in, with ok false when the store is closed at t. Hours
+// starting the day before are checked since they may run past midnight.
+func (hours StoreHours) openingAround(t time.Time) (time.Time, bool) {
+	date := midnight(t.In(hours.Location()))
+	for _, day := range []time.Time{date.AddDate(0, 0, -1), date} {
+		if start, end, ok := hours.interval(day); ok && !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Gets when the store opens and closes for the hours of the day starting at the given local midnight, with ok
+// false when it is closed all that day
+func (hours StoreHours) interval(date time.Time) (time.Time, time.Time, bool) {
+	nextDate := date.AddDate(0, 0, 1)
+	if hours.Open24 {
+		return date, nextDate, true
+	}
+
+	day := hours.Day(date.Weekday())
+	if day.Open24 {
+		return date, nextDate, true
+	}
+	opens, closes, ok := day.span()
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	start := atClock(date, opens)
+	end := atClock(date, closes)
+	if !end.After(start) {
+		end = atClock(nextDate, closes)
+	}
+	return start, end, true
+}
+
+// Gets the opening and closing times of the day as offsets from midnight, with ok false when the store is
+// closed that day or the times cannot be read
+func (day DayHours) span() (time.Duration, time.Duration, bool) {
+	if day.Open24 {
+		return 0, 24 * time.Hour, true
+	}
+
+	opens, err := parseClock(day.Open)
+	if err != nil {
+		return 0, 0, false
+	}
+	closes, err := parseClock(day.Close)
+	if err != nil {
+		return 0, 0, false
+	}
+	return opens, closes, true
+}
+
+// Parses a local time like "06:00" or "06:00:00" as an offset from midnight
+func parseClock(value string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if clock, err := time.Parse(layout, value); err == nil {
+			return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute + time.Duration(clock.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day '%s'", value)
+}
+
+// Gets the midnight starting the day of t in t's location
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Gets the time on the day of t when the clock reads the given offset from midnight. This differs from
+// adding the offset to midnight on days when daylight saving time starts or ends.
+func atClock(t time.Time, clock time.Duration) time.Time {
+	h, m, sec := int(clock/time.Hour), int(clock%time.Hour/time.Minute), int(clock%time.Minute/time.Second)
+	return time.Date(t.Year(), t.Month(), t.Day(), h, m, sec, 0, t.Location())
+}
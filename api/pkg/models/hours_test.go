@@ -0,0 +1,176 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// Hours of a Portland store that are the same every day
+func dailyHours(opens string, closes string) StoreHours {
+	day := DayHours{Open: opens, Close: closes}
+	return StoreHours{
+		Timezone:  "America/Los_Angeles",
+		GmtOffset: "(UTC-08:00) Pacific Time (US & Canada)",
+		Monday:    day,
+		Tuesday:   day,
+		Wednesday: day,
+		Thursday:  day,
+		Friday:    day,
+		Saturday:  day,
+		Sunday:    day,
+	}
+}
+
+func pacific(t *testing.T, value string) time.Time {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("failed to load timezone, %v", err)
+	}
+	parsed, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatalf("failed to parse time, %v", err)
+	}
+	return parsed
+}
+
+func TestStoreHoursIsOpenAt(t *testing.T) {
+	lateNight := dailyHours("06:00", "01:00")
+	lateNight.Sunday = DayHours{}
+
+	testCases := []struct {
+		name     string
+		hours    StoreHours
+		at       string
+		expected bool
+	}{
+		{"before opening", dailyHours("06:00", "23:00"), "2022-06-01 05:59", false},
+		{"at opening", dailyHours("06:00", "23:00"), "2022-06-01 06:00", true},
+		{"at closing", dailyHours("06:00", "23:00"), "2022-06-01 23:00", false},
+		{"closes at midnight", dailyHours("06:00", "00:00"), "2022-06-01 23:59", true},
+		{"after midnight", lateNight, "2022-06-02 00:30", true},
+		{"after late closing", lateNight, "2022-06-02 01:00", false},
+		{"after midnight into a closed day", lateNight, "2022-06-05 00:30", true},
+		{"closed day", lateNight, "2022-06-05 12:00", false},
+		{"after midnight from a closed day", lateNight, "2022-06-06 00:30", false},
+		{"open 24 hours", StoreHours{Open24: true}, "2022-06-01 03:00", true},
+		{"open 24 hours on the day", StoreHours{Timezone: "America/Los_Angeles", Wednesday: DayHours{Open24: true}}, "2022-06-01 03:00", true},
+		{"unknown hours", StoreHours{}, "2022-06-01 12:00", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.hours.IsOpenAt(pacific(t, tc.at)); actual != tc.expected {
+				t.Errorf("expected %v but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStoreHoursTimezone(t *testing.T) {
+	// 14:00 UTC is 07:00 in Portland, when the store is open, and 22:00 in Singapore, when it is not
+	at := time.Date(2022, 6, 1, 14, 0, 0, 0, time.UTC)
+	hours := dailyHours("08:00", "20:00")
+	if hours.IsOpenAt(at) {
+		t.Error("expected the store to be closed at 07:00 Pacific")
+	}
+	hours = dailyHours("07:00", "20:00")
+	if !hours.IsOpenAt(at) {
+		t.Error("expected the store to be open at 07:00 Pacific")
+	}
+
+	// The GMT offset is used when the timezone is not recognized
+	hours.Timezone = "Nowhere/Unknown"
+	hours.GmtOffset = "(UTC+08:00) Singapore"
+	if hours.IsOpenAt(at) {
+		t.Error("expected the store to be closed at 22:00 in UTC+8")
+	}
+	if _, offset := at.In(hours.Location()).Zone(); offset != 8*60*60 {
+		t.Errorf("expected an offset of 8 hours but got %d seconds", offset)
+	}
+}
+
+func TestStoreHoursNextOpening(t *testing.T) {
+	closedSunday := dailyHours("06:00", "23:00")
+	closedSunday.Sunday = DayHours{}
+
+	testCases := []struct {
+		name     string
+		hours    StoreHours
+		at       string
+		expected string
+		ok       bool
+	}{
+		{"open", closedSunday, "2022-06-01 12:00", "2022-06-01 12:00", true},
+		{"early morning", closedSunday, "2022-06-01 05:00", "2022-06-01 06:00", true},
+		{"evening", closedSunday, "2022-06-01 23:30", "2022-06-02 06:00", true},
+		{"over a closed day", closedSunday, "2022-06-04 23:30", "2022-06-06 06:00", true},
+		{"unknown hours", StoreHours{}, "2022-06-01 12:00", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := tc.hours.NextOpening(pacific(t, tc.at))
+			if ok != tc.ok {
+				t.Fatalf("expected ok %v but got %v", tc.ok, ok)
+			} else if ok && !actual.Equal(pacific(t, tc.expected)) {
+				t.Errorf("expected %s but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStoreHoursClosesAt(t *testing.T) {
+	allNightWeekend := dailyHours("06:00", "23:00")
+	allNightWeekend.Friday = DayHours{Open: "06:00", Close: "00:00"}
+	allNightWeekend.Saturday = DayHours{Open24: true}
+	allNightWeekend.Sunday = DayHours{Open: "00:00", Close: "22:00"}
+
+	testCases := []struct {
+		name     string
+		hours    StoreHours
+		at       string
+		expected string
+		ok       bool
+	}{
+		{"open", dailyHours("06:00", "23:00"), "2022-06-01 12:00", "2022-06-01 23:00", true},
+		{"past midnight", dailyHours("06:00", "01:00"), "2022-06-01 23:00", "2022-06-02 01:00", true},
+		{"open through the weekend", allNightWeekend, "2022-06-03 12:00", "2022-06-05 22:00", true},
+		{"closed", dailyHours("06:00", "23:00"), "2022-06-01 23:30", "", false},
+		{"never closes", StoreHours{Open24: true}, "2022-06-01 12:00", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			at := pacific(t, tc.at)
+			actual, ok := tc.hours.ClosesAt(at)
+			if ok != tc.ok {
+				t.Fatalf("expected ok %v but got %v", tc.ok, ok)
+			} else if !ok {
+				return
+			}
+
+			expected := pacific(t, tc.expected)
+			if !actual.Equal(expected) {
+				t.Errorf("expected %s but got %v", tc.expected, actual)
+			}
+			if closesIn, _ := tc.hours.ClosesIn(at); closesIn != expected.Sub(at) {
+				t.Errorf("expected to close in %v but got %v", expected.Sub(at), closesIn)
+			}
+		})
+	}
+}
+
+func TestStoreHoursDaylightSaving(t *testing.T) {
+	// Clocks in Portland went forward an hour at 02:00 on Sunday 13 March 2022, so from 01:00 to noon was only
+	// ten hours
+	hours := dailyHours("06:00", "23:00")
+	hours.Sunday = DayHours{Open: "00:00", Close: "12:00"}
+
+	at := pacific(t, "2022-03-13 01:00")
+	if closesIn, ok := hours.ClosesIn(at); !ok || closesIn != 10*time.Hour {
+		t.Errorf("expected to close in 10h but got %v", closesIn)
+	}
+	if opening, ok := hours.NextOpening(pacific(t, "2022-03-13 12:30")); !ok || !opening.Equal(pacific(t, "2022-03-14 06:00")) {
+		t.Errorf("expected to open at 06:00 on Monday but got %v", opening)
+	}
+}
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	"github.com/jondysinger/grocery-data/api/pkg/money"
+)
+
 type AuthorizationResponse struct {
 	ExpiresIn   int    `json:"expires_in"`
 	AccessToken string `json:"access_token"`
@@ -34,52 +40,32 @@ type Location struct {
 		Longitude float32 `json:"longitude"`
 		LatLng    string  `json:"latLng"`
 	} `json:"geolocation"`
-	Name  string `json:"Name"`
-	Hours struct {
-		Timezone  string `json:"timezone"`
-		GmtOffset string `json:"gmtOffset"`
-		Open24    bool   `json:"open24"`
-		Monday    struct {
-			Open   string `json:"open"`
-			Close  string `json:"close"`
-			Open24 bool   `json:"open24"`
-		} `json:"monday"`
-		Tuesday struct {
-			Open   string `json:"open"`
-			Close  string `json:"close"`
-			Open24 bool   `json:"open24"`
-		} `json:"tuesday"`
-		Wednesday struct {
-			Open   string `json:"open"`
-			Close  string `json:"close"`
-			Open24 bool   `json:"open24"`
-		} `json:"wednesday"`
-		Thursday struct {
-			Open   string `json:"open"`
-			Close  string `json:"close"`
-			Open24 bool   `json:"open24"`
-		} `json:"thursday"`
-		Friday struct {
-			Open   string `json:"open"`
-			Close  string `json:"close"`
-			Open24 bool   `json:"open24"`
-		} `json:"friday"`
-		Saturday struct {
-			Open   string `json:"open"`
-			Close  string `json:"close"`
-			Open24 bool   `json:"open24"`
-		} `json:"saturday"`
-		Sunday struct {
-			Open   string `json:"open"`
-			Close  string `json:"close"`
-			Open24 bool   `json:"open24"`
-		} `json:"sunday"`
-	} `json:"hours"`
-	Phone       string `json:"phone"`
-	Departments []struct {
-		DepartmentID string `json:"departmentId"`
-		Name         string `json:"name"`
-	} `json:"departments"`
+	Name        string       `json:"Name"`
+	Hours       StoreHours   `json:"hours"`
+	Phone       string       `json:"phone"`
+	Departments []Department `json:"departments"`
+}
+
+// Checks whether the location is one of the given IDs. Any location matches when no IDs are given.
+func (location Location) MatchesIds(locationIds []string) bool {
+	return len(locationIds) == 0 || containsString(locationIds, location.LocationId)
+}
+
+// Checks whether the location has all of the given departments
+func (location Location) HasDepartments(departmentIds []string) bool {
+	for _, departmentId := range departmentIds {
+		found := false
+		for _, department := range location.Departments {
+			if department.DepartmentID == departmentId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 type LocationsResponse struct {
@@ -91,52 +77,68 @@ type LocationsResponse struct {
 			Limit int `json:"limit"`
 		} `json:"pagination"`
 		Warnings []string `json:"warnings"`
+		Freshness
+	} `json:"meta"`
+}
+
+type LocationResponse struct {
+	Data Location `json:"data"`
+	Meta struct {
+		Warnings []string `json:"warnings"`
+		Freshness
+	} `json:"meta"`
+}
+
+type Chain struct {
+	Name            string   `json:"name"`
+	DivisionNumbers []string `json:"divisionNumbers"`
+}
+
+type ChainsResponse struct {
+	Data []Chain `json:"data"`
+	Meta struct {
+		Warnings []string `json:"warnings"`
+		Freshness
+	} `json:"meta"`
+}
+
+type ChainResponse struct {
+	Data Chain `json:"data"`
+	Meta struct {
+		Warnings []string `json:"warnings"`
+		Freshness
+	} `json:"meta"`
+}
+
+type Department struct {
+	DepartmentID string `json:"departmentId"`
+	Name         string `json:"name"`
+}
+
+type DepartmentsResponse struct {
+	Data []Department `json:"data"`
+	Meta struct {
+		Warnings []string `json:"warnings"`
+		Freshness
+	} `json:"meta"`
+}
+
+type DepartmentResponse struct {
+	Data Department `json:"data"`
+	Meta struct {
+		Warnings []string `json:"warnings"`
+		Freshness
 	} `json:"meta"`
 }
 
 type Product struct {
-	ProductId      string `json:"productId"`
-	AisleLocations []struct {
-		BayNumber          string `json:"bayNumber"`
-		Description        string `json:"description"`
-		Number             string `json:"number"`
-		NumberOfFacings    string `json:"numberOfFacings"`
-		SequenceNumber     string `json:"sequenceNumber"`
-		Side               string `json:"side"`
-		ShelfNumber        string `json:"shelfNumber"`
-		ShelfPositionInBay string `json:"shelfPositionInBay"`
-	} `json:"aisleLocations"`
-	Brand         string   `json:"brand"`
-	Categories    []string `json:"categories"`
-	CountryOrigin string   `json:"countryOrigin"`
-	Description   string   `json:"description"`
-	Items         []struct {
-		ItemId    string `json:"itemId"`
-		Inventory struct {
-			StockLevel string `json:"stockLevel"`
-		} `json:"inventory"`
-		Favorite    bool `json:"favorite"`
-		Fulfillment struct {
-			Curbside   bool `json:"curbside"`
-			Delivery   bool `json:"delivery"`
-			InStore    bool `json:"instore"`
-			ShipToHome bool `json:"shiptohome"`
-		} `json:"fulfillment"`
-		Price struct {
-			Regular                float32 `json:"regular"`
-			Promo                  float32 `json:"promo"`
-			RegularPerUnitEstimate float32 `json:"regularPerUnitEstimate"`
-			PromoPerUnitEstimate   float32 `json:"promoPerUnitEstimate"`
-		} `json:"price"`
-		NationalPrice struct {
-			Regular                float32 `json:"regular"`
-			Promo                  float32 `json:"promo"`
-			RegularPerUnitEstimate float32 `json:"regularPerUnitEstimate"`
-			PromoPerUnitEstimate   float32 `json:"promoPerUnitEstimate"`
-		} `json:"nationalPrice"`
-		Size   string `json:"size"`
-		SoldBy string `json:"soldBy"`
-	} `json:"items"`
+	ProductId       string          `json:"productId"`
+	AisleLocations  []AisleLocation `json:"aisleLocations"`
+	Brand           string          `json:"brand"`
+	Categories      []string        `json:"categories"`
+	CountryOrigin   string          `json:"countryOrigin"`
+	Description     string          `json:"description"`
+	Items           []Item          `json:"items"`
 	ItemInformation struct {
 		Depth  string `json:"depth"`
 		Height string `json:"height"`
@@ -146,17 +148,76 @@ type Product struct {
 		Indicator     string `json:"indicator"`
 		HeatSensitive bool   `json:"heatSensitive"`
 	} `json:"temperature"`
-	Images []struct {
-		Id          string `json:"id"`
-		Perspective string `json:"perspective"`
-		Default     bool   `json:"default"`
-		Sizes       []struct {
-			Id   string `json:"id"`
-			Size string `json:"size"`
-			Url  string `json:"url"`
-		} `json:"sizes"`
-	} `json:"images"`
-	Upc string `json:"upc"`
+	Images []Image `json:"images"`
+	Upc    string  `json:"upc"`
+}
+
+// A picture of a product from one perspective, such as "front", in several sizes
+type Image struct {
+	Id          string `json:"id"`
+	Perspective string `json:"perspective"`
+	Default     bool   `json:"default"`
+	Sizes       []struct {
+		Id   string `json:"id"`
+		Size string `json:"size"`
+		Url  string `json:"url"`
+	} `json:"sizes"`
+}
+
+// A way a product is sold, such as a size or flavor, with its price and stock level at a store
+type Item struct {
+	ItemId    string `json:"itemId"`
+	Inventory struct {
+		StockLevel string `json:"stockLevel"`
+	} `json:"inventory"`
+	Favorite    bool `json:"favorite"`
+	Fulfillment struct {
+		Curbside   bool `json:"curbside"`
+		Delivery   bool `json:"delivery"`
+		InStore    bool `json:"instore"`
+		ShipToHome bool `json:"shiptohome"`
+	} `json:"fulfillment"`
+	Price         Price  `json:"price"`
+	NationalPrice Price  `json:"nationalPrice"`
+	Size          string `json:"size"`
+	SoldBy        string `json:"soldBy"`
+}
+
+// Checks whether the product is one of the given IDs. Any product matches when no IDs are given.
+func (product Product) MatchesIds(productIds []string) bool {
+	return len(productIds) == 0 || containsString(productIds, product.ProductId)
+}
+
+// Checks whether any of the product's items can be obtained with the fulfillment type, which is one of
+// Kroger's codes: "ais" for in store, "csp" for curbside pickup, "dth" for delivery and "sth" for shipping
+func (product Product) HasFulfillment(fulfillment string) bool {
+	for _, item := range product.Items {
+		switch {
+		case fulfillment == "ais" && item.Fulfillment.InStore,
+			fulfillment == "csp" && item.Fulfillment.Curbside,
+			fulfillment == "dth" && item.Fulfillment.Delivery,
+			fulfillment == "sth" && item.Fulfillment.ShipToHome:
+			return true
+		}
+	}
+	return false
+}
+
+// Regular and promotional prices of an item, as exact amounts. Kroger leaves the promo price at zero when the
+// item is not on promotion.
+type Price struct {
+	Regular                money.Money `json:"regular"`
+	Promo                  money.Money `json:"promo"`
+	RegularPerUnitEstimate money.Money `json:"regularPerUnitEstimate"`
+	PromoPerUnitEstimate   money.Money `json:"promoPerUnitEstimate"`
+}
+
+// Gets the price a shopper pays, which is the promo price when there is one
+func (price Price) Effective() money.Money {
+	if price.Promo > 0 && price.Promo < price.Regular {
+		return price.Promo
+	}
+	return price.Regular
 }
 
 type ProductsResponse struct {
@@ -168,11 +229,65 @@ type ProductsResponse struct {
 			Limit int `json:"limit"`
 		} `json:"pagination"`
 		Warnings []string `json:"warnings"`
+		Freshness
+	} `json:"meta"`
+}
+
+type ProductResponse struct {
+	Data Product `json:"data"`
+	Meta struct {
+		Warnings []string `json:"warnings"`
+		Freshness
 	} `json:"meta"`
 }
 
+// Marks a response that was served from the cache after it expired, because the Kroger API failed or while
+// it is being refreshed in the background
+type Freshness struct {
+	Stale bool `json:"stale,omitempty"`
+	// Seconds since the response was fetched from the Kroger API
+	Age int `json:"age,omitempty"`
+}
+
 type JsonResponse struct {
 	Error   bool        `json:"error"`
+	Code    string      `json:"code,omitempty"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+type EndpointUsage struct {
+	Endpoint string `json:"endpoint"`
+	Used     int    `json:"used"`
+	// Zero when the endpoint has no daily limit
+	DailyLimit int `json:"dailyLimit"`
+	// Only set when the endpoint has a daily limit
+	Remaining int       `json:"remaining"`
+	PerSecond float64   `json:"perSecond"`
+	ResetsAt  time.Time `json:"resetsAt"`
+}
+
+type UsageResponse struct {
+	Data []EndpointUsage `json:"data"`
+}
+
+type CoalesceStats struct {
+	// Calls made to the Kroger API
+	Calls int64 `json:"calls"`
+	// Calls that shared an identical call already in flight instead of making their own
+	Saved int64 `json:"saved"`
+}
+
+type CoalesceResponse struct {
+	Data CoalesceStats `json:"data"`
+}
+
+// Checks whether the list contains the value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
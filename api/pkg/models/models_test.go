@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestLocationFilters(t *testing.T) {
+	location := Location{LocationId: "70100393", Departments: []Department{{DepartmentID: "09"}, {DepartmentID: "1B"}}}
+
+	testCases := []struct {
+		name          string
+		locationIds   []string
+		departmentIds []string
+		expected      bool
+	}{
+		{"no filters", nil, nil, true},
+		{"matching id", []string{"70100150", "70100393"}, nil, true},
+		{"other id", []string{"70100150"}, nil, false},
+		{"all departments", nil, []string{"1B", "09"}, true},
+		{"missing department", nil, []string{"09", "DE"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if matches := location.MatchesIds(tc.locationIds) && location.HasDepartments(tc.departmentIds); matches != tc.expected {
+				t.Errorf("expected match %v but got %v", tc.expected, matches)
+			}
+		})
+	}
+}
+
+func TestProductMatchesIds(t *testing.T) {
+	product := Product{ProductId: "0001111041700"}
+	if !product.MatchesIds(nil) || !product.MatchesIds([]string{"0001111041700"}) {
+		t.Errorf("expected the product to match")
+	} else if product.MatchesIds([]string{"0004138703023"}) {
+		t.Errorf("expected the product not to match another id")
+	}
+}
@@ -0,0 +1,247 @@
+// Package money provides an exact amount of US dollars for prices, so totals, savings and comparisons do not
+// pick up the rounding errors of floating point numbers.
+package money
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// An amount of dollars counted in ten-thousandths of a dollar. Prices are whole cents, and the extra two
+// places keep per-unit prices such as $0.0234 per ounce exact. The zero value is $0.
+type Money int64
+
+// Common amounts, used like time.Second, e.g. 2*money.Dollar + 99*money.Cent
+const (
+	Cent   Money = 100
+	Dollar Money = 100 * Cent
+)
+
+// Number of decimal places a Money holds
+const places = 4
+
+// Largest number of whole dollars parsed, keeping amounts well within an int64
+const maxDollarDigits = 14
+
+// Gets the amount for a number of cents
+func FromCents(cents int64) Money {
+	return Money(cents) * Cent
+}
+
+// Gets the amount nearest to a floating point number of dollars, e.g. a price from a float32 field
+func FromFloat(dollars float64) Money {
+	return Money(math.Round(dollars * float64(Dollar)))
+}
+
+// Parses a decimal number of dollars such as "2.99", "-0.5" or "$1,299.00". Digits beyond the fourth decimal
+// place are rounded half away from zero.
+func Parse(value string) (Money, error) {
+	s := strings.TrimSpace(value)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	s = strings.ReplaceAll(strings.TrimPrefix(s, "$"), ",", "")
+
+	// JSON allows exponents, which Kroger does not use, so they are parsed as floats
+	if strings.ContainsAny(s, "eE") {
+		dollars, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.Abs(dollars) >= math.Pow10(maxDollarDigits) {
+			return 0, fmt.Errorf("invalid amount '%s'", value)
+		}
+		if negative {
+			dollars = -dollars
+		}
+		return FromFloat(dollars), nil
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if (whole == "" && fraction == "") || !isDigits(whole) || !isDigits(fraction) || len(whole) > maxDollarDigits {
+		return 0, fmt.Errorf("invalid amount '%s'", value)
+	}
+
+	var amount int64
+	for _, digit := range whole {
+		amount = amount*10 + int64(digit-'0')
+	}
+	for i := 0; i < places; i++ {
+		amount *= 10
+		if i < len(fraction) {
+			amount += int64(fraction[i] - '0')
+		}
+	}
+	if len(fraction) > places && fraction[places] >= '5' {
+		amount++
+	}
+
+	if negative {
+		amount = -amount
+	}
+	return Money(amount), nil
+}
+
+// Parses an amount that is known to be valid, panicking if it is not. For constants and tests.
+func MustParse(value string) Money {
+	amount, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+// Adds up amounts
+func Sum(amounts ...Money) Money {
+	var total Money
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
+
+// Gets the sum of two amounts
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+// Gets the difference of two amounts
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+// Gets the amount multiplied by a whole number, such as a quantity
+func (m Money) Mul(n int64) Money {
+	return m * Money(n)
+}
+
+// Gets the amount divided by a whole number, rounded half away from zero
+func (m Money) Div(n int64) Money {
+	if n == 0 {
+		panic(errors.New("money: division by zero"))
+	}
+
+	// Division truncates toward zero, so step away from zero when the remainder is at least half the divisor
+	quotient, remainder := int64(m)/n, int64(m)%n
+	if abs(remainder)*2 >= abs(n) {
+		if (m < 0) != (n < 0) {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return Money(quotient)
+}
+
+// Gets the amount multiplied by a factor, such as a rate or a fraction of a unit, rounded to the nearest
+// ten-thousandth of a dollar
+func (m Money) MulFloat(factor float64) Money {
+	return Money(math.Round(float64(m) * factor))
+}
+
+// Gets the amount rounded to whole cents, half away from zero
+func (m Money) Round() Money {
+	return FromCents(m.Cents())
+}
+
+// Gets the amount in whole cents, rounded half away from zero
+func (m Money) Cents() int64 {
+	return int64(m.Div(int64(Cent)))
+}
+
+// Gets the amount as a floating point number of dollars, for when exactness no longer matters
+func (m Money) Float() float64 {
+	return float64(m) / float64(Dollar)
+}
+
+// Checks whether the amount is zero
+func (m Money) IsZero() bool {
+	return m == 0
+}
+
+// Gets the amount as a decimal with at least two decimal places, e.g. "2.99", "3.00" or "0.0234"
+func (m Money) String() string {
+	return m.decimal(2)
+}
+
+// Gets the amount for display with a dollar sign and thousands separators, rounded to cents, e.g. "$1,299.00"
+// or "-$0.50"
+func (m Money) Format() string {
+	s := m.Round().decimal(2)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return fmt.Sprintf("%s$%s.%s", sign, grouped.String(), fraction)
+}
+
+// Writes the amount as a JSON number with as few decimal places as needed, as Kroger does, e.g. 2.99 or 3
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.decimal(0)), nil
+}
+
+// Reads the amount from a JSON number without going through a float, so 2.99 is exactly $2.99. Strings
+// holding a number are accepted too, and null leaves the amount unchanged.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	value := string(data)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	amount, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Formats the amount as a decimal with trailing zeros removed, keeping at least minPlaces decimal places
+func (m Money) decimal(minPlaces int) string {
+	amount := int64(m)
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	whole := amount / int64(Dollar)
+	fraction := fmt.Sprintf("%04d", amount%int64(Dollar))
+	for len(fraction) > minPlaces && strings.HasSuffix(fraction, "0") {
+		fraction = fraction[:len(fraction)-1]
+	}
+
+	if fraction == "" {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	return fmt.Sprintf("%s%d.%s", sign, whole, fraction)
+}
+
+// Gets the absolute value of a number
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Checks whether a string contains only decimal digits
+func isDigits(value string) bool {
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected Money
+	}{
+		{"2.99", 29900},
+		{"3", 30000},
+		{"0.5", 5000},
+		{".25", 2500},
+		{"-1.50", -15000},
+		{"$1,299.00", 12990000},
+		{"0.0234", 234},
+		{"2.98999", 29900},
+		{"2.99994", 29999},
+		{"-0.00005", -1},
+		{"2.99e0", 29900},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := Parse(tc.value)
+			if err != nil {
+				t.Fatalf("expected success but got error, %v", err)
+			} else if actual != tc.expected {
+				t.Errorf("expected %d but got %d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, value := range []string{"", "-", ".", "two", "1.2.3", "1.-5", "123456789012345.00", "1e20"} {
+		t.Run(value, func(t *testing.T) {
+			if _, err := Parse(value); err == nil {
+				t.Errorf("expected err but got none")
+			}
+		})
+	}
+}
+
+func TestFloatPricesAddUpExactly(t *testing.T) {
+	// Ten items at $0.10 come to exactly a dollar, which float32 prices do not
+	var total Money
+	for i := 0; i < 10; i++ {
+		total = total.Add(MustParse("0.10"))
+	}
+	if total != Dollar {
+		t.Errorf("expected $1.00 but got %s", total)
+	}
+
+	var floatTotal float32
+	for i := 0; i < 10; i++ {
+		floatTotal += 0.1
+	}
+	if FromFloat(float64(floatTotal)) != Dollar {
+		t.Errorf("expected a float32 total to round to $1.00 but got %s", FromFloat(float64(floatTotal)))
+	}
+	if FromFloat(float64(float32(2.99))) != MustParse("2.99") {
+		t.Errorf("expected a float32 price to convert exactly")
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	price := MustParse("2.99")
+	if actual := price.Mul(3); actual != MustParse("8.97") {
+		t.Errorf("expected 8.97 but got %s", actual)
+	}
+	if actual := price.Sub(MustParse("0.50")); actual != MustParse("2.49") {
+		t.Errorf("expected 2.49 but got %s", actual)
+	}
+	if actual := Sum(price, price, FromCents(2)); actual != 6*Dollar {
+		t.Errorf("expected 6.00 but got %s", actual)
+	}
+	if actual := price.MulFloat(0.5); actual != MustParse("1.495") {
+		t.Errorf("expected 1.495 but got %s", actual)
+	}
+}
+
+func TestDivAndRound(t *testing.T) {
+	testCases := []struct {
+		name     string
+		amount   Money
+		divisor  int64
+		expected Money
+	}{
+		{"exact", 30000, 3, 10000},
+		{"rounds down", 10000, 3, 3333},
+		{"rounds up", 20000, 3, 6667},
+		{"half away from zero", 5, 2, 3},
+		{"negative half away from zero", -5, 2, -3},
+		{"negative divisor", 5, -2, -3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.amount.Div(tc.divisor); actual != tc.expected {
+				t.Errorf("expected %d but got %d", tc.expected, actual)
+			}
+		})
+	}
+
+	if cents := MustParse("1.495").Cents(); cents != 150 {
+		t.Errorf("expected 150 cents but got %d", cents)
+	}
+	if rounded := MustParse("-1.4949").Round(); rounded != MustParse("-1.49") {
+		t.Errorf("expected -1.49 but got %s", rounded)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	testCases := []struct {
+		amount    Money
+		str       string
+		formatted string
+		json      string
+	}{
+		{MustParse("2.99"), "2.99", "$2.99", "2.99"},
+		{MustParse("3"), "3.00", "$3.00", "3"},
+		{MustParse("2.5"), "2.50", "$2.50", "2.5"},
+		{MustParse("0.0234"), "0.0234", "$0.02", "0.0234"},
+		{MustParse("-0.5"), "-0.50", "-$0.50", "-0.5"},
+		{MustParse("1299"), "1299.00", "$1,299.00", "1299"},
+		{MustParse("1234567.891"), "1234567.891", "$1,234,567.89", "1234567.891"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.str, func(t *testing.T) {
+			if actual := tc.amount.String(); actual != tc.str {
+				t.Errorf("expected String %s but got %s", tc.str, actual)
+			}
+			if actual := tc.amount.Format(); actual != tc.formatted {
+				t.Errorf("expected Format %s but got %s", tc.formatted, actual)
+			}
+			if actual, err := json.Marshal(tc.amount); err != nil || string(actual) != tc.json {
+				t.Errorf("expected JSON %s but got %s", tc.json, actual)
+			}
+		})
+	}
+}
+
+func TestJsonRoundTrip(t *testing.T) {
+	// Kroger's price shape
+	body := `{"regular":2.99,"promo":0,"regularPerUnitEstimate":"0.0234","missing":null}`
+	var price struct {
+		Regular                Money `json:"regular"`
+		Promo                  Money `json:"promo"`
+		RegularPerUnitEstimate Money `json:"regularPerUnitEstimate"`
+		Missing                Money `json:"missing"`
+	}
+	if err := json.Unmarshal([]byte(body), &price); err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	}
+	if price.Regular != MustParse("2.99") || price.Promo != 0 || price.RegularPerUnitEstimate != 234 || price.Missing != 0 {
+		t.Errorf("unexpected price %+v", price)
+	}
+
+	out, err := json.Marshal(price)
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if string(out) != `{"regular":2.99,"promo":0,"regularPerUnitEstimate":0.0234,"missing":0}` {
+		t.Errorf("unexpected JSON %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"regular":"cheap"}`), &price); err == nil {
+		t.Errorf("expected err but got none")
+	}
+}
@@ -0,0 +1,219 @@
+// Package unitsize reads the package sizes Kroger gives as free text, such as "16 oz", "1/2 gal" or
+// "12 ct / 12 fl oz", and converts them between units so products of different sizes can be compared by
+// their price per unit.
+package unitsize
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jondysinger/grocery-data/api/pkg/money"
+)
+
+// Kind of quantity a unit measures. Units convert only to units of the same family.
+type Family string
+
+const (
+	Weight Family = "weight"
+	Volume Family = "volume"
+	Count  Family = "count"
+)
+
+// Unit of measure. Base is the size of one unit in its family's base unit, which is grams for weight,
+// millilitres for volume and single items for count.
+type Unit struct {
+	Name   string
+	Family Family
+	Base   float64
+}
+
+// Units sizes are given in and converted to
+var (
+	Gram       = Unit{"g", Weight, 1}
+	Kilogram   = Unit{"kg", Weight, 1000}
+	Ounce      = Unit{"oz", Weight, 28.349523125}
+	Pound      = Unit{"lb", Weight, 453.59237}
+	Millilitre = Unit{"ml", Volume, 1}
+	Litre      = Unit{"l", Volume, 1000}
+	FluidOunce = Unit{"fl oz", Volume, 29.5735295625}
+	Cup        = Unit{"cup", Volume, 236.5882365}
+	Pint       = Unit{"pt", Volume, 473.176473}
+	Quart      = Unit{"qt", Volume, 946.352946}
+	Gallon     = Unit{"gal", Volume, 3785.411784}
+	Item       = Unit{"ct", Count, 1}
+)
+
+// Units prices are normalized to for each family
+var perUnit = map[Family]Unit{
+	Weight: Ounce,
+	Volume: FluidOunce,
+	Count:  Item,
+}
+
+// Spellings of each unit, after lower casing and removing dots
+var unitNames = map[string]Unit{
+	"g": Gram, "gr": Gram, "gram": Gram, "grams": Gram,
+	"kg": Kilogram, "kilogram": Kilogram, "kilograms": Kilogram,
+	"oz": Ounce, "ounce": Ounce, "ounces": Ounce,
+	"lb": Pound, "lbs": Pound, "pound": Pound, "pounds": Pound,
+	"ml": Millilitre, "millilitre": Millilitre, "milliliter": Millilitre, "millilitres": Millilitre, "milliliters": Millilitre,
+	"l": Litre, "lt": Litre, "ltr": Litre, "litre": Litre, "liter": Litre, "litres": Litre, "liters": Litre,
+	"fl oz": FluidOunce, "floz": FluidOunce, "fluid ounce": FluidOunce, "fluid ounces": FluidOunce,
+	"cup": Cup, "cups": Cup,
+	"pt": Pint, "pint": Pint, "pints": Pint,
+	"qt": Quart, "quart": Quart, "quarts": Quart,
+	"gal": Gallon, "gallon": Gallon, "gallons": Gallon,
+	"ct": Item, "count": Item, "each": Item, "ea": Item, "pk": Item, "pack": Item, "pc": Item, "pcs": Item,
+	"piece": Item, "pieces": Item, "x": Item,
+}
+
+// Matches a quantity and the word after it: a mixed number ("1 1/2"), fraction ("1/2") or decimal ("0.5",
+// ".5"), optionally followed by a unit, which may be two words for fluid ounces
+var termRegexp = regexp.MustCompile(`(?i)(\d+\s+\d+/\d+|\d+/\d+|\d*\.?\d+)\s*(fl\.?\s*oz\.?|fluid\s+ounces?|[a-z]+\.?)?`)
+
+// Returned when a size has no quantity with a recognized unit
+var ErrUnknownSize = errors.New("unknown size")
+
+// A package size, such as 12 cans of 12 fl oz
+type Size struct {
+	// Amount in each item of the package
+	Quantity float64
+	Unit     Unit
+	// Number of items in the package, 1 unless the size is a multipack like "12 ct / 12 fl oz"
+	Count float64
+}
+
+// Parses a size like "16 oz", "1/2 gal", "1.75 L", "18 ct", "1 lb 8 oz" or "12 ct / 12 fl oz". A count
+// alongside a weight or volume, before it or after an "x" as in "16 oz x 2", is read as a multipack of that
+// many items of that size. Weights or volumes in a row add up, while one after a "/" or in parentheses, as in
+// "1 lb / 454 g", restates the amount and is ignored.
+func Parse(text string) (Size, error) {
+	size := Size{Count: 1}
+	found, hasAmount := false, false
+	previousEnd := 0
+	for _, match := range termRegexp.FindAllStringSubmatchIndex(text, -1) {
+		between := strings.ToLower(strings.TrimSpace(text[previousEnd:match[0]]))
+		previousEnd = match[1]
+
+		var unitName string
+		if match[4] >= 0 {
+			unitName = text[match[4]:match[5]]
+		}
+		unit, ok := lookupUnit(unitName)
+		if !ok && between == "x" {
+			// A multiplier after the size, e.g. "16 oz x 2"
+			unit, ok = Item, true
+		} else if !ok {
+			continue
+		}
+		quantity, err := parseQuantity(text[match[2]:match[3]])
+		if err != nil || quantity <= 0 {
+			continue
+		}
+
+		switch {
+		case unit.Family == Count:
+			size.Count *= quantity
+		case !hasAmount:
+			size.Quantity, size.Unit = quantity, unit
+			hasAmount = true
+		case strings.ContainsAny(between, "/(") || unit.Family != size.Unit.Family:
+			// The same amount in other units, e.g. "1 lb / 454 g"
+			continue
+		default:
+			// The rest of a compound amount, e.g. "1 lb 8 oz"
+			size.Quantity += quantity * unit.Base / size.Unit.Base
+		}
+		found = true
+	}
+
+	if !found {
+		return Size{}, fmt.Errorf("%w: '%s'", ErrUnknownSize, text)
+	}
+	// A size that only gives a count is that many single items
+	if !hasAmount {
+		size.Quantity, size.Unit, size.Count = size.Count, Item, 1
+	}
+	return size, nil
+}
+
+// Gets the total amount in the package, e.g. 144 fl oz for "12 ct / 12 fl oz"
+func (size Size) Total() float64 {
+	return size.Quantity * size.Count
+}
+
+// Gets the total amount in the package in another unit of the same family
+func (size Size) In(unit Unit) (float64, error) {
+	if unit.Family != size.Unit.Family {
+		return 0, fmt.Errorf("cannot convert %s to %s", size.Unit.Name, unit.Name)
+	}
+	return size.Total() * size.Unit.Base / unit.Base, nil
+}
+
+// Gets the price of one of the given unit when the package costs price
+func (size Size) PricePer(price money.Money, unit Unit) (money.Money, error) {
+	amount, err := size.In(unit)
+	if err != nil {
+		return 0, err
+	} else if amount <= 0 {
+		return 0, fmt.Errorf("%w: empty package", ErrUnknownSize)
+	}
+	return price.MulFloat(1 / amount), nil
+}
+
+// Gets the price of an item per ounce, fluid ounce or single item depending on its size, so items can be
+// compared whatever their package size. Items sold by weight are priced per pound by Kroger, so their size is
+// ignored and they are converted from pounds.
+func UnitPrice(price money.Money, sizeText string, soldBy string) (money.Money, Unit, error) {
+	size := Size{Quantity: 1, Unit: Pound, Count: 1}
+	if !strings.EqualFold(soldBy, "WEIGHT") {
+		var err error
+		if size, err = Parse(sizeText); err != nil {
+			return 0, Unit{}, err
+		}
+	}
+
+	unit := perUnit[size.Unit.Family]
+	unitPrice, err := size.PricePer(price, unit)
+	if err != nil {
+		return 0, Unit{}, err
+	}
+	return unitPrice, unit, nil
+}
+
+// Looks up a unit by any of its spellings
+func lookupUnit(name string) (Unit, bool) {
+	name = strings.ToLower(strings.ReplaceAll(name, ".", ""))
+	name = strings.Join(strings.Fields(name), " ")
+	unit, ok := unitNames[name]
+	return unit, ok
+}
+
+// Parses a mixed number, fraction or decimal
+func parseQuantity(text string) (float64, error) {
+	var total float64
+	for _, part := range strings.Fields(text) {
+		if numerator, denominator, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.ParseFloat(numerator, 64)
+			if err != nil {
+				return 0, err
+			}
+			d, err := strconv.ParseFloat(denominator, 64)
+			if err != nil || d == 0 {
+				return 0, fmt.Errorf("invalid fraction '%s'", part)
+			}
+			total += n / d
+			continue
+		}
+
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, err
+		}
+		total += value
+	}
+	return total, nil
+}
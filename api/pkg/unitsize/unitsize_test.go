@@ -0,0 +1,140 @@
+package unitsize
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/jondysinger/grocery-data/api/pkg/money"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		text     string
+		quantity float64
+		unit     Unit
+		count    float64
+	}{
+		{"16 oz", 16, Ounce, 1},
+		{"16oz", 16, Ounce, 1},
+		{"1 gal", 1, Gallon, 1},
+		{"1/2 gal", 0.5, Gallon, 1},
+		{"0.5 gal", 0.5, Gallon, 1},
+		{"1 1/2 lb", 1.5, Pound, 1},
+		{"5 LBS", 5, Pound, 1},
+		{"64 fl oz", 64, FluidOunce, 1},
+		{"16.9 Fl. Oz.", 16.9, FluidOunce, 1},
+		{"1.75 L", 1.75, Litre, 1},
+		{"500 ml", 500, Millilitre, 1},
+		{"18 ct", 18, Item, 1},
+		{"1 each", 1, Item, 1},
+		{"12 ct / 12 fl oz", 12, FluidOunce, 12},
+		{"6 pk/16.9 fl oz", 16.9, FluidOunce, 6},
+		{"2 x 16 oz", 16, Ounce, 2},
+		{"1 lb / 454 g", 1, Pound, 1},
+		{"16 oz (1 lb)", 16, Ounce, 1},
+		{"1 lb 8 oz", 1.5, Pound, 1},
+		{"1 lb 8 oz / 680 g", 1.5, Pound, 1},
+		{"16 oz x 2", 16, Ounce, 2},
+		{"24 oz bag", 24, Ounce, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			size, err := Parse(tc.text)
+			if err != nil {
+				t.Fatalf("expected success but got error, %v", err)
+			}
+			if math.Abs(size.Quantity-tc.quantity) > 1e-9 || size.Unit != tc.unit || size.Count != tc.count {
+				t.Errorf("expected %v %s x %v but got %v %s x %v", tc.quantity, tc.unit.Name, tc.count, size.Quantity, size.Unit.Name, size.Count)
+			}
+		})
+	}
+}
+
+func TestParseCompound(t *testing.T) {
+	// A pound and a half is 24 ounces, not the first pound alone
+	size, err := Parse("1 lb 8 oz")
+	if err != nil {
+		t.Fatalf("expected success but got error, %v", err)
+	} else if ounces, _ := size.In(Ounce); math.Abs(ounces-24) > 0.001 {
+		t.Errorf("expected 24 oz but got %v", ounces)
+	}
+}
+
+func TestParseUnknown(t *testing.T) {
+	for _, text := range []string{"", "large", "1 bunch", "0 oz"} {
+		t.Run(text, func(t *testing.T) {
+			if _, err := Parse(text); !errors.Is(err, ErrUnknownSize) {
+				t.Errorf("expected ErrUnknownSize but got %v", err)
+			}
+		})
+	}
+}
+
+func TestIn(t *testing.T) {
+	testCases := []struct {
+		name     string
+		size     Size
+		unit     Unit
+		expected float64
+	}{
+		{"gallon to fluid ounces", Size{1, Gallon, 1}, FluidOunce, 128},
+		{"multipack to fluid ounces", Size{12, FluidOunce, 12}, FluidOunce, 144},
+		{"litre to fluid ounces", Size{1, Litre, 1}, FluidOunce, 33.814},
+		{"pound to ounces", Size{1, Pound, 1}, Ounce, 16},
+		{"ounces to grams", Size{16, Ounce, 1}, Gram, 453.592},
+		{"kilogram to pounds", Size{1, Kilogram, 1}, Pound, 2.20462},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := tc.size.In(tc.unit)
+			if err != nil {
+				t.Fatalf("expected success but got error, %v", err)
+			} else if math.Abs(actual-tc.expected) > 0.001 {
+				t.Errorf("expected %v but got %v", tc.expected, actual)
+			}
+		})
+	}
+
+	// Weights and volumes cannot be converted to each other without knowing the density
+	if _, err := (Size{1, Gallon, 1}).In(Pound); err == nil {
+		t.Errorf("expected err but got none")
+	}
+}
+
+func TestUnitPrice(t *testing.T) {
+	testCases := []struct {
+		name     string
+		price    string
+		size     string
+		soldBy   string
+		expected string
+		unit     Unit
+	}{
+		{"gallon", "3.84", "1 gal", "UNIT", "0.03", FluidOunce},
+		{"multipack", "7.20", "12 ct / 12 fl oz", "UNIT", "0.05", FluidOunce},
+		{"pounds", "4.80", "2 lb", "UNIT", "0.15", Ounce},
+		{"pounds and ounces", "4.80", "1 lb 8 oz", "UNIT", "0.20", Ounce},
+		{"grams", "2.99", "100 g", "UNIT", "0.8477", Ounce},
+		{"count", "3.60", "18 ct", "UNIT", "0.20", Item},
+		{"sold by weight", "8.00", "", "WEIGHT", "0.50", Ounce},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			unitPrice, unit, err := UnitPrice(money.MustParse(tc.price), tc.size, tc.soldBy)
+			if err != nil {
+				t.Fatalf("expected success but got error, %v", err)
+			}
+			if unitPrice != money.MustParse(tc.expected) || unit != tc.unit {
+				t.Errorf("expected %s per %s but got %s per %s", tc.expected, tc.unit.Name, unitPrice, unit.Name)
+			}
+		})
+	}
+
+	if _, _, err := UnitPrice(money.MustParse("1.00"), "large", "UNIT"); !errors.Is(err, ErrUnknownSize) {
+		t.Errorf("expected ErrUnknownSize but got %v", err)
+	}
+}
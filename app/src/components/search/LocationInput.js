@@ -25,8 +25,8 @@ const LocationInput = ({
       >
         <option value={placeholder}>{placeholder}</option>
         {locations.map((location) => (
-          <option key={location.locationId} value={location.locationId}>
-            {location.Name}
+          <option key={location.id} value={location.id}>
+            {location.name}
           </option>
         ))}
       </select>
@@ -13,29 +13,23 @@ function getProductThumbnail(product) {
   if (!product.images) {
     return "";
   }
-  let frontImages = product.images.find((i) => {
-    return i.perspective === "front";
-  });
-  if (!frontImages || !frontImages.sizes) {
-    return "";
-  }
-  let thumbnailImage = frontImages.sizes.find((s) => {
-    return s.size === "thumbnail";
+  let thumbnailImage = product.images.find((i) => {
+    return i.perspective === "front" && i.size === "thumbnail";
   });
   return thumbnailImage?.url ?? "";
 }
 
 function getProductInventoryLevel(product) {
-  if (!product.items || product.items.length == 0) {
+  if (!product.offers || product.offers.length == 0) {
     return "";
   }
-  let item = product.items[0];
-  switch (item.inventory.stockLevel) {
-    case "HIGH":
+  let offer = product.offers[0];
+  switch (offer.stockLevel) {
+    case "high":
       return "High";
-    case "LOW":
+    case "low":
       return "Low";
-    case "TEMPORARILY_OUT_OF_STOCK":
+    case "out":
       return "Out of stock";
     default:
       return "Unknown";
@@ -78,7 +72,7 @@ const ProductList = ({
       <tbody>
         {products.data.map((product) => {
           return (
-            <tr key={product.productId}>
+            <tr key={product.id}>
               <td className="text-center">
                 <img
                   src={getProductThumbnail(product)}
@@ -38,7 +38,7 @@ export function SearchPage({
     setLoadingProducts(true);
     await loadProducts(
       searchTerm,
-      props.selectedLocation.locationId,
+      props.selectedLocation.id,
       offset,
       searchDefaults.PRODUCTS_PER_PAGE
     );
@@ -48,7 +48,7 @@ export function SearchPage({
   function handleLocationChange(event) {
     const { value } = event.target;
     let selectedLocation = props.locations.find((loc) => {
-      return loc.locationId === value;
+      return loc.id === value;
     });
     setLocation(selectedLocation);
   }
@@ -124,7 +124,7 @@ export function SearchPage({
         name="location"
         label="Location:"
         locations={props.locations}
-        value={props.selectedLocation?.locationId}
+        value={props.selectedLocation?.id}
         onChange={handleLocationChange}
         placeholder="Select a location"
       />